@@ -1,14 +1,26 @@
 package main
 
 import (
+	"chirpy/internal/auth"
 	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"database/sql"
 	"sync/atomic"
 )
 
 type apiConfig struct {
-	fileserverHits atomic.Int32
-	queries        *database.Queries
-	platform       string
-	secretToken    string
-	polkaKey       string
+	fileserverHits       atomic.Int32 // for /admin/metrics; /admin/reset zeroes it
+	db                   *sql.DB
+	queries              *database.Queries
+	platform             string
+	secretToken          string
+	keys                 *auth.Keyring
+	polkaKeys            []string // current secret first, then the previous one while rotating
+	mailer               mailer.Mailer
+	requireVerifiedEmail bool
+	appURL               string // base URL for links in emails
+	proxyHops            int    // proxies whose X-Forwarded-For entries clientIP trusts
+	metrics              *serverMetrics
+	metricsToken         string // bearer token /metrics requires, if set
+	schemaVersion        int64  // newest migration built in, checked by /api/readyz
 }
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// errMissingScope means the caller authenticated but their token doesn't
+// allow the request. Handlers answer it with 403 rather than 401.
+var errMissingScope = errors.New("token is missing a required scope")
+
+// authenticatedUserID returns the user the request's bearer access token
+// was issued to.
+func (cfg *apiConfig) authenticatedUserID(r *http.Request) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if auth.IsPersonalAccessToken(token) {
+		return uuid.Nil, errors.New("personal access tokens can't be used here")
+	}
+	return cfg.keys.ValidateJWT(token)
+}
+
+// authorizedUserID is authenticatedUserID for endpoints that also accept
+// personal access tokens and tokens issued to OAuth clients, as long as the
+// token was granted scope.
+func (cfg *apiConfig) authorizedUserID(r *http.Request, scope string) (uuid.UUID, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !auth.IsPersonalAccessToken(token) {
+		return cfg.delegatedUserID(r, token, scope)
+	}
+	pat, err := cfg.queries.GetPersonalAccessTokenByHash(r.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, errors.New("personal access token is invalid, expired or revoked")
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !auth.HasScope(pat.Scopes, scope) {
+		return uuid.Nil, fmt.Errorf("%w: %s", errMissingScope, scope)
+	}
+	err = cfg.queries.TouchPersonalAccessToken(r.Context(), pat.ID)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return pat.UserID, nil
+}
+
+// delegatedUserID validates a JWT access token. Tokens from a password login
+// can do anything; tokens issued to OAuth clients need scope and must not
+// have been revoked.
+func (cfg *apiConfig) delegatedUserID(r *http.Request, token, scope string) (uuid.UUID, error) {
+	claims, err := cfg.keys.ParseAccessToken(token)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if !claims.Delegated() {
+		return claims.UserID()
+	}
+	if !auth.HasScope(claims.Scopes(), scope) {
+		return uuid.Nil, fmt.Errorf("%w: %s", errMissingScope, scope)
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid token ID: %w", err)
+	}
+	issued, err := cfg.queries.GetOAuthAccessToken(r.Context(), tokenID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && issued.RevokedAt.Valid) {
+		return uuid.Nil, errors.New("token has been revoked")
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID()
+}
+
+// optionalUserID is authenticatedUserID for endpoints that also serve
+// anonymous callers. It returns uuid.Nil when the request has no
+// Authorization header, and an error only for a token that doesn't validate.
+// Personal access tokens need the chirps:read scope.
+func (cfg *apiConfig) optionalUserID(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+	return cfg.authorizedUserID(r, auth.ScopeChirpsRead)
+}
+
+// requireRole only lets requests through whose access token was issued to
+// a user with at least role. It uses the role claim, so a changed role
+// applies once the user's access token is refreshed. Tokens issued to
+// OAuth clients and personal access tokens carry no role and never pass.
+func (cfg *apiConfig) requireRole(role string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+			return
+		}
+		claims, err := cfg.keys.ParseAccessToken(token)
+		if err != nil {
+			respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+			return
+		}
+		if claims.Delegated() || !auth.HasRole(claims.Role, role) {
+			respondWithError(w, http.StatusForbidden, fmt.Sprintf("Requires the %s role", role))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
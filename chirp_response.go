@@ -0,0 +1,104 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"github.com/google/uuid"
+)
+
+type chirpResponse struct {
+	database.Chirp
+	LikedByMe bool          `json:"liked_by_me"`
+	Entities  chirpEntities `json:"entities"`
+	// ReferencedChirp is the chirp a rechirp or quote-chirp points at. It is
+	// only embedded one level deep.
+	ReferencedChirp *chirpResponse `json:"referenced_chirp,omitempty"`
+}
+
+func referencedChirpID(c database.Chirp) uuid.NullUUID {
+	if c.RechirpOf.Valid {
+		return c.RechirpOf
+	}
+	return c.QuoteOf
+}
+
+// chirpResponses adds the per-viewer fields and referenced chirps to chirps
+// read from the database. viewerID is uuid.Nil for anonymous callers.
+func (cfg *apiConfig) chirpResponses(
+	ctx context.Context,
+	viewerID uuid.UUID,
+	chirps []database.Chirp,
+) ([]chirpResponse, error) {
+	res := make([]chirpResponse, 0, len(chirps))
+	if len(chirps) == 0 {
+		return res, nil
+	}
+
+	refIDs := []uuid.UUID{}
+	for _, c := range chirps {
+		if ref := referencedChirpID(c); ref.Valid {
+			refIDs = append(refIDs, ref.UUID)
+		}
+	}
+	referenced := map[uuid.UUID]database.Chirp{}
+	if len(refIDs) > 0 {
+		refs, err := cfg.queries.GetChirpsByIDs(ctx, refIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range refs {
+			referenced[c.ID] = c
+		}
+	}
+
+	ids := make([]uuid.UUID, 0, len(chirps)+len(referenced))
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+	}
+	for id := range referenced {
+		ids = append(ids, id)
+	}
+
+	mentions := map[uuid.UUID][]mentionEntity{}
+	mentionRows, err := cfg.queries.GetChirpMentions(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range mentionRows {
+		mentions[m.ChirpID] = append(mentions[m.ChirpID], mentionEntity{
+			UserID:   m.UserID,
+			Username: m.Username,
+			Start:    m.StartOffset,
+			End:      m.EndOffset,
+		})
+	}
+	entities := func(id uuid.UUID) chirpEntities {
+		if mentions[id] == nil {
+			return chirpEntities{Mentions: []mentionEntity{}}
+		}
+		return chirpEntities{Mentions: mentions[id]}
+	}
+
+	liked := map[uuid.UUID]bool{}
+	if viewerID != uuid.Nil {
+		likedIDs, err := cfg.queries.GetLikedChirpIDs(
+			ctx,
+			database.GetLikedChirpIDsParams{UserID: viewerID, ChirpIds: ids},
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range likedIDs {
+			liked[id] = true
+		}
+	}
+
+	for _, c := range chirps {
+		resp := chirpResponse{Chirp: c, LikedByMe: liked[c.ID], Entities: entities(c.ID)}
+		if ref, ok := referenced[referencedChirpID(c).UUID]; ok {
+			resp.ReferencedChirp = &chirpResponse{Chirp: ref, LikedByMe: liked[ref.ID], Entities: entities(ref.ID)}
+		}
+		res = append(res, resp)
+	}
+	return res, nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseProxyHops reads TRUST_PROXY_HEADERS: the number of proxies in front
+// of Chirpy, with "true" meaning one. Empty or "false" trusts none.
+func parseProxyHops(s string) (int, error) {
+	switch s {
+	case "", "false":
+		return 0, nil
+	case "true":
+		return 1, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("TRUST_PROXY_HEADERS must be true, false or a number of proxies, got %q", s)
+	}
+	return n, nil
+}
+
+// clientIP returns the address the request came from. X-Forwarded-For is
+// only honored when TRUST_PROXY_HEADERS is set, since any client can send
+// it. Each proxy appends the address it received the request from, so only
+// entries counted from the right were written by our proxies: with n of
+// them, the n-th entry from the right is the client. Anything further left
+// came from the client and may be made up.
+func (cfg *apiConfig) clientIP(r *http.Request) string {
+	if cfg.proxyHops > 0 {
+		entries := []string{}
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			entries = append(entries, strings.Split(header, ",")...)
+		}
+		if len(entries) >= cfg.proxyHops {
+			if ip := net.ParseIP(strings.TrimSpace(entries[len(entries)-cfg.proxyHops])); ip != nil {
+				return ip.String()
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		name      string
+		proxyHops int
+		forwarded []string
+		want      string
+	}{
+		{
+			name:      "Proxy headers not trusted",
+			proxyHops: 0,
+			forwarded: []string{"198.51.100.9"},
+			want:      "192.0.2.1",
+		},
+		{
+			name:      "One proxy",
+			proxyHops: 1,
+			forwarded: []string{"203.0.113.7"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "Spoofed entries ahead of the proxy's are ignored",
+			proxyHops: 1,
+			forwarded: []string{"198.51.100.9, 10.0.0.1, 203.0.113.7"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "Two proxies",
+			proxyHops: 2,
+			forwarded: []string{"198.51.100.9, 203.0.113.7, 10.0.0.2"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "Entries split across header lines",
+			proxyHops: 2,
+			forwarded: []string{"198.51.100.9", "203.0.113.7", "10.0.0.2"},
+			want:      "203.0.113.7",
+		},
+		{
+			name:      "Fewer entries than proxies",
+			proxyHops: 2,
+			forwarded: []string{"203.0.113.7"},
+			want:      "192.0.2.1",
+		},
+		{
+			name:      "Invalid entry",
+			proxyHops: 1,
+			forwarded: []string{"198.51.100.9, not-an-ip"},
+			want:      "192.0.2.1",
+		},
+		{
+			name:      "No header",
+			proxyHops: 1,
+			want:      "192.0.2.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{proxyHops: tt.proxyHops}
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = "192.0.2.1:4321"
+			for _, f := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", f)
+			}
+			if got := cfg.clientIP(r); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProxyHops(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "false", want: 0},
+		{value: "true", want: 1},
+		{value: "2", want: 2},
+		{value: "-1", wantErr: true},
+		{value: "yes", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseProxyHops(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseProxyHops(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseProxyHops(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+)
+
+// runCommand runs an administrative subcommand instead of the server.
+func runCommand(ctx context.Context, db *sql.DB, args []string) error {
+	switch args[0] {
+	case "promote-admin":
+		if len(args) != 2 {
+			return errors.New("usage: chirpy promote-admin <email>")
+		}
+		return promoteFirstAdmin(ctx, db, args[1])
+	default:
+		return fmt.Errorf("unknown command %q", args[0])
+	}
+}
+
+// promoteFirstAdmin makes the user with email an admin. It only works while
+// there are no admins; after that, admins promote users through the API.
+func promoteFirstAdmin(ctx context.Context, db *sql.DB, email string) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	q := database.New(tx)
+	admins, err := q.CountUsersWithRole(ctx, auth.RoleAdmin)
+	if err != nil {
+		return err
+	}
+	if admins > 0 {
+		return errors.New("an admin already exists; ask them to promote you with PUT /admin/users/{userID}/role")
+	}
+	user, err := q.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("no user with email %s", email)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = q.SetUserRole(ctx, database.SetUserRoleParams{Role: auth.RoleAdmin, ID: user.ID})
+	if err != nil {
+		return err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	log.Printf("%s is now an admin", email)
+	return nil
+}
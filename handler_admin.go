@@ -0,0 +1,57 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// setUserRole changes another user's role. Admins can't change their own,
+// so the last admin can't lock everyone out by accident.
+func (cfg *apiConfig) setUserRole(w http.ResponseWriter, r *http.Request) {
+	type roleBody struct {
+		Role string `json:"role"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	adminID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing user ID: %v", err))
+		return
+	}
+	if userID == adminID {
+		respondWithError(w, http.StatusBadRequest, "You can't change your own role")
+		return
+	}
+	body := roleBody{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	role, err := auth.ParseRole(body.Role)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	user, err := cfg.queries.SetUserRole(r.Context(), database.SetUserRoleParams{Role: role, ID: userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error setting role: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, user)
+}
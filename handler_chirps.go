@@ -3,13 +3,17 @@ package main
 import (
 	"chirpy/internal/auth"
 	"chirpy/internal/database"
+	"chirpy/internal/webhook"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
 	"net/http"
 	"strings"
 )
 
+const maxChirpLength = 140
+
 func cleanChirp(chirp string) string {
 	badWords := map[string]bool{
 		"kerfuffle": true,
@@ -30,20 +34,30 @@ func cleanChirp(chirp string) string {
 
 func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
 	type chirpBody struct {
-		Body string `json:"body"`
+		Body      string        `json:"body"`
+		InReplyTo uuid.NullUUID `json:"in_reply_to"`
+		QuoteOf   uuid.NullUUID `json:"quote_of"`
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error getting token: %v", err))
+	userID, err := cfg.authorizedUserID(r, auth.ScopeChirpsWrite)
+	if errors.Is(err, errMissingScope) {
+		respondWithError(w, http.StatusForbidden, err.Error())
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.secretToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
 		return
 	}
+	blocked, err := cfg.mustVerifyEmail(r, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before posting")
+		return
+	}
 
 	decoder := json.NewDecoder(r.Body)
 	chirp := chirpBody{}
@@ -53,17 +67,80 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if len(chirp.Body) > 140 {
+	if len(chirp.Body) > maxChirpLength {
 		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
 		return
 	}
+	if chirp.InReplyTo.Valid {
+		parent, err := cfg.queries.GetChirp(r.Context(), chirp.InReplyTo.UUID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting parent chirp: %v", err))
+			return
+		}
+		chirp.InReplyTo.UUID = originalChirpID(parent)
+	}
+	if chirp.QuoteOf.Valid {
+		quoted, err := cfg.queries.GetChirp(r.Context(), chirp.QuoteOf.UUID)
+		if err != nil {
+			respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting quoted chirp: %v", err))
+			return
+		}
+		chirp.QuoteOf.UUID = originalChirpID(quoted)
+	}
 	cleanedChirp := cleanChirp(chirp.Body)
-	res, err := cfg.queries.CreateChirp(r.Context(), database.CreateChirpParams{Body: cleanedChirp, UserID: userID})
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	res, err := qtx.CreateChirp(r.Context(), database.CreateChirpParams{
+		Body:      cleanedChirp,
+		UserID:    userID,
+		InReplyTo: chirp.InReplyTo,
+		QuoteOf:   chirp.QuoteOf,
+	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating chirp: %v", err))
 		return
 	}
-	respondWithJSON(w, http.StatusCreated, res)
+	err = saveChirpHashtags(r.Context(), qtx, res)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving hashtags: %v", err))
+		return
+	}
+	err = saveChirpMentions(r.Context(), qtx, res)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving mentions: %v", err))
+		return
+	}
+	err = enqueueWebhookEvent(r.Context(), qtx, webhook.EventChirpCreated, res)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error queueing webhooks: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), userID, []database.Chirp{res})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, resp[0])
+}
+
+type chirpsPage struct {
+	Chirps     []chirpResponse `json:"chirps"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+func chirpCursor(c database.Chirp) pageCursor {
+	return pageCursor{CreatedAt: c.CreatedAt, ID: c.ID}
 }
 
 func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
@@ -73,6 +150,18 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
 	if sort == "" {
 		sort = "asc"
 	}
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+
+	var res []database.Chirp
 	if authorId != "" {
 		authorID, err := uuid.Parse(authorId)
 		if err != nil {
@@ -80,41 +169,53 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		if sort == "asc" {
-			res, err := cfg.queries.GetChirpsByUserId(r.Context(), authorID)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
-				return
-			}
-			respondWithJSON(w, http.StatusOK, res)
+			res, err = cfg.queries.GetChirpsByUserId(r.Context(), database.GetChirpsByUserIdParams{
+				UserID:         authorID,
+				AfterCreatedAt: page.afterCreatedAt(),
+				AfterID:        page.afterID(),
+				PageSize:       page.fetchLimit(),
+			})
 		} else {
-			res, err := cfg.queries.GetChirpsByUserIdDesc(r.Context(), authorID)
-			if err != nil {
-				respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
-				return
-			}
-			respondWithJSON(w, http.StatusOK, res)
+			res, err = cfg.queries.GetChirpsByUserIdDesc(r.Context(), database.GetChirpsByUserIdDescParams{
+				UserID:         authorID,
+				AfterCreatedAt: page.afterCreatedAt(),
+				AfterID:        page.afterID(),
+				PageSize:       page.fetchLimit(),
+			})
 		}
+	} else if sort == "asc" {
+		res, err = cfg.queries.GetChirps(r.Context(), database.GetChirpsParams{
+			AfterCreatedAt: page.afterCreatedAt(),
+			AfterID:        page.afterID(),
+			PageSize:       page.fetchLimit(),
+		})
+	} else {
+		res, err = cfg.queries.GetChirpsDesc(r.Context(), database.GetChirpsDescParams{
+			AfterCreatedAt: page.afterCreatedAt(),
+			AfterID:        page.afterID(),
+			PageSize:       page.fetchLimit(),
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
 		return
 	}
-	if sort == "asc" {
-		res, err := cfg.queries.GetChirps(r.Context())
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
-			return
-		}
-		respondWithJSON(w, http.StatusOK, res)
-	} else {
-		res, err := cfg.queries.GetChirpsDesc(r.Context())
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
-			return
-		}
-		respondWithJSON(w, http.StatusOK, res)
+	chirps, nextCursor := paginate(res, page, chirpCursor)
+	resp, err := cfg.chirpResponses(r.Context(), viewerID, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
+		return
 	}
+	respondWithJSON(w, http.StatusOK, chirpsPage{Chirps: resp, NextCursor: nextCursor})
 }
 
 func (cfg *apiConfig) getChirp(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
 	chirpID := r.PathValue("chirpID")
 	uuidChirpID, err := uuid.Parse(chirpID)
 	if err != nil {
@@ -126,17 +227,21 @@ func (cfg *apiConfig) getChirp(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
 		return
 	}
-	respondWithJSON(w, http.StatusOK, res)
+	resp, err := cfg.chirpResponses(r.Context(), viewerID, []database.Chirp{res})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp[0])
 }
 
 func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error getting token: %v", err))
+	userID, err := cfg.authorizedUserID(r, auth.ScopeChirpsWrite)
+	if errors.Is(err, errMissingScope) {
+		respondWithError(w, http.StatusForbidden, err.Error())
 		return
 	}
-	userID, err := auth.ValidateJWT(token, cfg.secretToken)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
 		return
@@ -154,13 +259,55 @@ func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	if chirp.UserID != userID {
-		respondWithError(w, http.StatusForbidden, "User does not own chirp")
+		// Moderators can take down anyone's chirp. The role is read from
+		// the database since personal access tokens don't carry it.
+		user, err := cfg.queries.GetUserByID(r.Context(), userID)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+			return
+		}
+		if !auth.HasRole(user.Role, auth.RoleModerator) {
+			respondWithError(w, http.StatusForbidden, "User does not own chirp")
+			return
+		}
+	}
+
+	// Deleting a chirp from the middle of a thread splices it out: its
+	// replies move up to its parent so the rest of the conversation stays
+	// connected.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
 		return
 	}
-	err = cfg.queries.DeleteChirp(r.Context(), database.DeleteChirpParams{ID: uuidChirpID, UserID: userID})
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	err = qtx.ReparentReplies(r.Context(), database.ReparentRepliesParams{
+		NewParentID: chirp.InReplyTo,
+		ChirpID:     chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error reparenting replies: %v", err))
+		return
+	}
+	err = qtx.DeleteChirp(r.Context(), database.DeleteChirpParams{ID: uuidChirpID, UserID: chirp.UserID})
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error deleting chirp: %v", err))
 		return
 	}
+	err = enqueueWebhookEvent(r.Context(), qtx, webhook.EventChirpDeleted, chirpDeletedEvent{
+		ID:        chirp.ID,
+		UserID:    chirp.UserID,
+		DeletedBy: userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error queueing webhooks: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
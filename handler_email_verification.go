@@ -0,0 +1,105 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// verifyEmail confirms an address with a token from a verification email.
+// For a pending change, this is when the new address replaces the old one.
+func (cfg *apiConfig) verifyEmail(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		respondWithError(w, http.StatusBadRequest, "Missing token")
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	verification, err := qtx.UseEmailVerificationToken(r.Context(), auth.HashToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Verification token is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error using verification token: %v", err))
+		return
+	}
+	res, err := qtx.VerifyUserEmail(r.Context(), database.VerifyUserEmailParams{
+		Email: verification.Email,
+		ID:    verification.UserID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "This email change was replaced by a newer one")
+		return
+	}
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email is already taken")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error verifying email: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+// resendEmailVerification sends a fresh verification email for the pending
+// address, or for the current one if it was never verified.
+func (cfg *apiConfig) resendEmailVerification(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	user, err := cfg.queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	email := user.PendingEmail
+	if email == "" {
+		if user.EmailVerified {
+			respondWithError(w, http.StatusConflict, "Email is already verified")
+			return
+		}
+		email = user.Email
+	}
+	msg, err := cfg.createEmailVerification(r.Context(), cfg.queries, userID, email)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating email verification: %v", err))
+		return
+	}
+	cfg.sendMailAsync(msg)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// mustVerifyEmail reports whether userID is barred from posting because
+// REQUIRE_VERIFIED_EMAIL is on and their address isn't verified yet.
+func (cfg *apiConfig) mustVerifyEmail(r *http.Request, userID uuid.UUID) (bool, error) {
+	if !cfg.requireVerifiedEmail {
+		return false, nil
+	}
+	verified, err := cfg.queries.IsEmailVerified(r.Context(), userID)
+	if err != nil {
+		return false, err
+	}
+	return !verified, nil
+}
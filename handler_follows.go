@@ -0,0 +1,186 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"time"
+)
+
+type followEntry struct {
+	UserID      uuid.UUID `json:"user_id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+	FollowedAt  time.Time `json:"followed_at"`
+}
+
+type followsPage struct {
+	Users      []followEntry `json:"users"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+func followCursor(f followEntry) pageCursor {
+	return pageCursor{CreatedAt: f.FollowedAt, ID: f.UserID}
+}
+
+func (cfg *apiConfig) followUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing user ID: %v", err))
+		return
+	}
+	if followeeID == userID {
+		respondWithError(w, http.StatusBadRequest, "Users can't follow themselves")
+		return
+	}
+	_, err = cfg.queries.GetUserByID(r.Context(), followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	err = cfg.queries.FollowUser(r.Context(), database.FollowUserParams{FollowerID: userID, FolloweeID: followeeID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error following user: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	followeeID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing user ID: %v", err))
+		return
+	}
+	err = cfg.queries.UnfollowUser(r.Context(), database.UnfollowUserParams{FollowerID: userID, FolloweeID: followeeID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error unfollowing user: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getFollowers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing user ID: %v", err))
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	res, err := cfg.queries.GetFollowers(r.Context(), database.GetFollowersParams{
+		UserID:         userID,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		PageSize:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting followers: %v", err))
+		return
+	}
+	entries := make([]followEntry, 0, len(res))
+	for _, f := range res {
+		entries = append(entries, followEntry{
+			UserID:      f.UserID,
+			Username:    f.Username,
+			DisplayName: f.DisplayName,
+			FollowedAt:  f.FollowedAt,
+		})
+	}
+	entries, nextCursor := paginate(entries, page, followCursor)
+	respondWithJSON(w, http.StatusOK, followsPage{Users: entries, NextCursor: nextCursor})
+}
+
+func (cfg *apiConfig) getFollowing(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing user ID: %v", err))
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	res, err := cfg.queries.GetFollowing(r.Context(), database.GetFollowingParams{
+		UserID:         userID,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		PageSize:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting followed users: %v", err))
+		return
+	}
+	entries := make([]followEntry, 0, len(res))
+	for _, f := range res {
+		entries = append(entries, followEntry{
+			UserID:      f.UserID,
+			Username:    f.Username,
+			DisplayName: f.DisplayName,
+			FollowedAt:  f.FollowedAt,
+		})
+	}
+	entries, nextCursor := paginate(entries, page, followCursor)
+	respondWithJSON(w, http.StatusOK, followsPage{Users: entries, NextCursor: nextCursor})
+}
+
+func (cfg *apiConfig) getTimeline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authorizedUserID(r, auth.ScopeChirpsRead)
+	if errors.Is(err, errMissingScope) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	res, err := cfg.queries.GetTimeline(r.Context(), database.GetTimelineParams{
+		UserID:         userID,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		PageSize:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting timeline: %v", err))
+		return
+	}
+	chirps, nextCursor := paginate(res, page, chirpCursor)
+	resp, err := cfg.chirpResponses(r.Context(), userID, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting timeline: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirpsPage{Chirps: resp, NextCursor: nextCursor})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	trendingTagsLimit     = 10
+)
+
+// hashtagPattern matches #tags that start a word, so URL fragments such as
+// example.com/#top aren't picked up. \p{M} keeps combining accents, as in
+// a decomposed "café", inside the tag.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{M}\p{N}_/&#])#([\p{L}\p{M}\p{N}_]+)`)
+
+func normalizeHashtag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+// extractHashtags returns the distinct normalized tags in body, in the order
+// they first appear.
+func extractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := normalizeHashtag(match[1])
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// saveChirpHashtags replaces the stored tags for chirp with the ones in its
+// current body.
+func saveChirpHashtags(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := q.DeleteChirpHashtags(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	tags := extractHashtags(chirp.Body)
+	if len(tags) == 0 {
+		return nil
+	}
+	return q.CreateChirpHashtags(ctx, database.CreateChirpHashtagsParams{
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		Tags:      tags,
+	})
+}
+
+func (cfg *apiConfig) getHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	tag := normalizeHashtag(r.PathValue("tag"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "Hashtag is required")
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	res, err := cfg.queries.GetChirpsByHashtag(r.Context(), database.GetChirpsByHashtagParams{
+		Tag:            tag,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		PageSize:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
+		return
+	}
+	chirps, nextCursor := paginate(res, page, chirpCursor)
+	resp, err := cfg.chirpResponses(r.Context(), viewerID, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirps: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirpsPage{Chirps: resp, NextCursor: nextCursor})
+}
+
+func (cfg *apiConfig) getTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	type trendingTag struct {
+		Tag           string  `json:"tag"`
+		Count         int64   `json:"count"`
+		PreviousCount int64   `json:"previous_count"`
+		Velocity      float64 `json:"velocity"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	window := defaultTrendingWindow
+	if param := r.URL.Query().Get("window"); param != "" {
+		d, err := time.ParseDuration(param)
+		if err != nil || d <= 0 || d > maxTrendingWindow {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("window must be a duration up to %v", maxTrendingWindow))
+			return
+		}
+		window = d
+	}
+
+	now := time.Now().UTC()
+	res, err := cfg.queries.GetTrendingHashtags(r.Context(), database.GetTrendingHashtagsParams{
+		WindowStart:         now.Add(-window),
+		PreviousWindowStart: now.Add(-2 * window),
+		MaxTags:             trendingTagsLimit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting trending hashtags: %v", err))
+		return
+	}
+	tags := make([]trendingTag, 0, len(res))
+	for _, t := range res {
+		tags = append(tags, trendingTag{
+			Tag:           t.Tag,
+			Count:         t.RecentCount,
+			PreviousCount: t.PreviousCount,
+			// Change in uses per hour between the previous window and this one.
+			Velocity: float64(t.RecentCount-t.PreviousCount) / window.Hours(),
+		})
+	}
+	respondWithJSON(w, http.StatusOK, tags)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []string
+	}{
+		{
+			name: "Tags are lowercased",
+			body: "#Go is great #golang",
+			want: []string{"go", "golang"},
+		},
+		{
+			name: "Duplicates keep the first position",
+			body: "#go #rust #GO #Go",
+			want: []string{"go", "rust"},
+		},
+		{
+			name: "Trailing punctuation ends a tag",
+			body: "Loving #gophers, #go! (#rust) #zig.",
+			want: []string{"gophers", "go", "rust", "zig"},
+		},
+		{
+			name: "Unicode letters and digits",
+			body: "#café #東京 #Straße #go2024",
+			want: []string{"café", "東京", "straße", "go2024"},
+		},
+		{
+			name: "Combining marks stay in the tag",
+			body: "#cafe\u0301 time",
+			want: []string{"cafe\u0301"},
+		},
+		{
+			name: "Not at the start of a word",
+			body: "issue#12 example.com/#top &#39; ##double",
+			want: []string{},
+		},
+		{
+			name: "Bare hash",
+			body: "# #, #!",
+			want: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractHashtags(tt.body)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("extractHashtags(%q) = %q, want %q", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (cfg *apiConfig) likeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.setChirpLike(w, r, true)
+}
+
+func (cfg *apiConfig) unlikeChirp(w http.ResponseWriter, r *http.Request) {
+	cfg.setChirpLike(w, r, false)
+}
+
+// setChirpLike adds or removes the caller's like and responds with the
+// chirp's updated counter. Both operations are idempotent. Liking a rechirp
+// likes the original chirp, which is what the response shows.
+func (cfg *apiConfig) setChirpLike(w http.ResponseWriter, r *http.Request, liked bool) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing chirp ID: %v", err))
+		return
+	}
+	target, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	chirpID = originalChirpID(target)
+	if liked {
+		err = cfg.queries.LikeChirp(r.Context(), database.LikeChirpParams{UserID: userID, ChirpID: chirpID})
+	} else {
+		err = cfg.queries.UnlikeChirp(r.Context(), database.UnlikeChirpParams{UserID: userID, ChirpID: chirpID})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating like: %v", err))
+		return
+	}
+	chirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), userID, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp[0])
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// mentionPattern matches @handles that start a word, so email addresses in
+// a chirp aren't treated as mentions. Where a handle ends is checked in
+// extractMentions, since \b only knows ASCII letters.
+var mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([A-Za-z0-9_]{3,30})`)
+
+// continuesWord reports whether s starts with a character that could be
+// part of a word, which means a handle before it was cut short.
+func continuesWord(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r == '_' || unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsNumber(r)
+}
+
+type mentionToken struct {
+	Handle string
+	// Start and End are rune offsets of the whole "@handle" token in the
+	// chirp body; End is exclusive.
+	Start int
+	End   int
+}
+
+type mentionEntity struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Start    int32     `json:"start"`
+	End      int32     `json:"end"`
+}
+
+type chirpEntities struct {
+	Mentions []mentionEntity `json:"mentions"`
+}
+
+func extractMentions(body string) []mentionToken {
+	tokens := []mentionToken{}
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		if continuesWord(body[match[3]:]) {
+			continue
+		}
+		// match[2:4] is the handle; the "@" is the byte just before it.
+		start := utf8.RuneCountInString(body[:match[2]-1])
+		handle := body[match[2]:match[3]]
+		tokens = append(tokens, mentionToken{
+			Handle: handle,
+			Start:  start,
+			End:    start + 1 + utf8.RuneCountInString(handle),
+		})
+	}
+	return tokens
+}
+
+// saveChirpMentions replaces the stored mentions for chirp with the ones in
+// its current body. Handles that don't belong to a user are left as text.
+func saveChirpMentions(ctx context.Context, q *database.Queries, chirp database.Chirp) error {
+	err := q.DeleteChirpMentions(ctx, chirp.ID)
+	if err != nil {
+		return err
+	}
+	tokens := extractMentions(chirp.Body)
+	if len(tokens) == 0 {
+		return nil
+	}
+	handles := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		handles = append(handles, strings.ToLower(t.Handle))
+	}
+	users, err := q.GetUsersByUsernames(ctx, handles)
+	if err != nil {
+		return err
+	}
+	userIDs := make(map[string]uuid.UUID, len(users))
+	for _, u := range users {
+		userIDs[strings.ToLower(u.Username)] = u.ID
+	}
+	for _, t := range tokens {
+		userID, ok := userIDs[strings.ToLower(t.Handle)]
+		if !ok {
+			continue
+		}
+		err = q.CreateChirpMention(ctx, database.CreateChirpMentionParams{
+			ChirpID:     chirp.ID,
+			UserID:      userID,
+			StartOffset: int32(t.Start),
+			EndOffset:   int32(t.End),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cfg *apiConfig) getMyMentions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authorizedUserID(r, auth.ScopeChirpsRead)
+	if errors.Is(err, errMissingScope) {
+		respondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	res, err := cfg.queries.GetChirpsMentioningUser(r.Context(), database.GetChirpsMentioningUserParams{
+		UserID:         userID,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		PageSize:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting mentions: %v", err))
+		return
+	}
+	chirps, nextCursor := paginate(res, page, chirpCursor)
+	resp, err := cfg.chirpResponses(r.Context(), userID, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting mentions: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirpsPage{Chirps: resp, NextCursor: nextCursor})
+}
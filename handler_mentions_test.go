@@ -0,0 +1,63 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExtractMentions(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []mentionToken
+	}{
+		{
+			name: "Offsets cover the @",
+			body: "hi @alice and @bob_2",
+			want: []mentionToken{{Handle: "alice", Start: 3, End: 9}, {Handle: "bob_2", Start: 14, End: 20}},
+		},
+		{
+			name: "Offsets count runes, not bytes",
+			body: "héllo 東京 @alice",
+			want: []mentionToken{{Handle: "alice", Start: 9, End: 15}},
+		},
+		{
+			name: "Duplicates are all kept",
+			body: "@alice @alice",
+			want: []mentionToken{{Handle: "alice", Start: 0, End: 6}, {Handle: "alice", Start: 7, End: 13}},
+		},
+		{
+			name: "Trailing punctuation ends a handle",
+			body: "(@alice), @bob's @carol.",
+			want: []mentionToken{
+				{Handle: "alice", Start: 1, End: 7},
+				{Handle: "bob", Start: 10, End: 14},
+				{Handle: "carol", Start: 17, End: 23},
+			},
+		},
+		{
+			name: "Email addresses aren't mentions",
+			body: "mail alice@example.com or @@bob",
+			want: []mentionToken{},
+		},
+		{
+			name: "Too short or too long",
+			body: "@al @abcdefghijklmnopqrstuvwxyz12345",
+			want: []mentionToken{},
+		},
+		{
+			name: "Handle followed by a non-ASCII letter",
+			body: "@josé @bobé",
+			want: []mentionToken{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := extractMentions(tt.body)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("extractMentions(%q) = %+v, want %+v", tt.body, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,684 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	oauthAccessTokenLifetime = time.Hour
+	maxOAuthClientNameLength = 100
+	maxOAuthRedirectURIs     = 10
+)
+
+// scopeDescriptions is what the consent page tells the user each scope
+// lets the client do.
+var scopeDescriptions = map[string]string{
+	auth.ScopeChirpsRead:  "Read chirps, your timeline and your mentions",
+	auth.ScopeChirpsWrite: "Post and delete chirps as you",
+}
+
+// oauthError is the error response format from RFC 6749 section 5.2.
+type oauthError struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, errorCode, description string) {
+	w.Header().Set("Cache-Control", "no-store")
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	respondWithJSON(w, code, oauthError{Error: errorCode, Description: description})
+}
+
+// validRedirectURI allows https, and plain http only back to the same
+// machine, for native apps and local development. The URI is compared
+// exactly at authorization time, so fragments can't be part of it.
+func validRedirectURI(raw string) bool {
+	u, err := url.Parse(raw)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" || u.User != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return true
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+	return false
+}
+
+// createOAuthClient registers a third-party app. Confidential clients get a
+// secret, shown only in this response; public clients (mobile and
+// single-page apps) can't keep one and rely on PKCE alone.
+func (cfg *apiConfig) createOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type clientBody struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+	type clientResponse struct {
+		ClientID     string    `json:"client_id"`
+		ClientSecret string    `json:"client_secret,omitempty"`
+		Name         string    `json:"name"`
+		RedirectURIs []string  `json:"redirect_uris"`
+		Confidential bool      `json:"confidential"`
+		CreatedAt    time.Time `json:"created_at"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	body := clientBody{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	if body.Name == "" || len(body.Name) > maxOAuthClientNameLength {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("name must be 1 to %d characters", maxOAuthClientNameLength))
+		return
+	}
+	if len(body.RedirectURIs) == 0 || len(body.RedirectURIs) > maxOAuthRedirectURIs {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Between 1 and %d redirect_uris are required", maxOAuthRedirectURIs))
+		return
+	}
+	for _, uri := range body.RedirectURIs {
+		if !validRedirectURI(uri) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Invalid redirect URI %q: use https, or http on localhost", uri))
+			return
+		}
+	}
+
+	clientID, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating client: %v", err))
+		return
+	}
+	clientID = clientID[:32]
+	secret := ""
+	secretHash := sql.NullString{}
+	if body.Confidential {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating client: %v", err))
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+	client, err := cfg.queries.CreateOAuthClient(r.Context(), database.CreateOAuthClientParams{
+		ID:           clientID,
+		SecretHash:   secretHash,
+		Name:         body.Name,
+		RedirectUris: body.RedirectURIs,
+		OwnerID:      userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving client: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, clientResponse{
+		ClientID:     client.ID,
+		ClientSecret: secret,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Confidential: client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	})
+}
+
+// authorizeRequest is a validated authorization request (RFC 6749 section
+// 4.1.1, with PKCE from RFC 7636).
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	State         string
+	Scopes        []string
+	CodeChallenge string
+}
+
+// authorizeError is a problem with an authorization request. Until the
+// client and redirect URI check out the error can only be shown to the
+// user; after that it is sent back to the client.
+type authorizeError struct {
+	code        string
+	description string
+	redirect    bool
+}
+
+func (e *authorizeError) Error() string {
+	return e.code + ": " + e.description
+}
+
+func (cfg *apiConfig) parseAuthorizeRequest(ctx context.Context, params url.Values) (authorizeRequest, error) {
+	req := authorizeRequest{State: params.Get("state")}
+	client, err := cfg.queries.GetOAuthClient(ctx, params.Get("client_id"))
+	if errors.Is(err, sql.ErrNoRows) {
+		return req, &authorizeError{code: "invalid_client", description: "Unknown client"}
+	}
+	if err != nil {
+		return req, err
+	}
+	req.Client = client
+
+	req.RedirectURI = params.Get("redirect_uri")
+	if req.RedirectURI == "" && len(client.RedirectUris) == 1 {
+		req.RedirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		return req, &authorizeError{code: "invalid_request", description: "redirect_uri is not registered for this client"}
+	}
+
+	if params.Get("response_type") != "code" {
+		return req, &authorizeError{code: "unsupported_response_type", description: "Only response_type=code is supported", redirect: true}
+	}
+	req.CodeChallenge = params.Get("code_challenge")
+	if req.CodeChallenge == "" || params.Get("code_challenge_method") != "S256" {
+		return req, &authorizeError{code: "invalid_request", description: "PKCE with code_challenge_method=S256 is required", redirect: true}
+	}
+	scopes, err := auth.ParseScopes(strings.Fields(params.Get("scope")))
+	if err != nil {
+		return req, &authorizeError{code: "invalid_scope", description: err.Error(), redirect: true}
+	}
+	if len(scopes) == 0 {
+		return req, &authorizeError{code: "invalid_scope", description: "At least one scope is required", redirect: true}
+	}
+	req.Scopes = scopes
+	return req, nil
+}
+
+// redirectToClient sends the browser back to the client with params added
+// to its redirect URI.
+func redirectToClient(w http.ResponseWriter, r *http.Request, req authorizeRequest, params url.Values) {
+	u, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		http.Error(w, "Invalid redirect URI", http.StatusInternalServerError)
+		return
+	}
+	q := u.Query()
+	for key, values := range params {
+		q[key] = values
+	}
+	if req.State != "" {
+		q.Set("state", req.State)
+	}
+	u.RawQuery = q.Encode()
+	code := http.StatusFound
+	if r.Method == http.MethodPost {
+		code = http.StatusSeeOther
+	}
+	http.Redirect(w, r, u.String(), code)
+}
+
+// respondToAuthorizeError either shows err to the user or, once the
+// redirect URI is trusted, hands it back to the client.
+func (cfg *apiConfig) respondToAuthorizeError(w http.ResponseWriter, r *http.Request, req authorizeRequest, err error) {
+	var authErr *authorizeError
+	if !errors.As(err, &authErr) {
+		renderAuthorizeError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking request: %v", err))
+		return
+	}
+	if !authErr.redirect {
+		renderAuthorizeError(w, http.StatusBadRequest, authErr.description)
+		return
+	}
+	redirectToClient(w, r, req, url.Values{
+		"error":             {authErr.code},
+		"error_description": {authErr.description},
+	})
+}
+
+var consentPage = template.Must(template.New("consent").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Authorize {{.ClientName}} - Chirpy</title>
+</head>
+<body>
+{{if .Message}}
+<h1>Can't authorize this app</h1>
+<p>{{.Message}}</p>
+{{else}}
+<h1>Authorize {{.ClientName}}</h1>
+<p>{{.ClientName}} wants to:</p>
+<ul>
+{{range .Scopes}}<li>{{.}}</li>
+{{end}}</ul>
+{{if .Error}}<p role="alert"><strong>{{.Error}}</strong></p>{{end}}
+<form method="post" action="/oauth/authorize">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<p><label>Email <input type="email" name="email" value="{{.Email}}" autocomplete="username" required></label></p>
+<p><label>Password <input type="password" name="password" autocomplete="current-password" required></label></p>
+<p><label>Two-factor code, if enabled <input type="text" name="code" autocomplete="one-time-code"></label></p>
+<p>
+<button type="submit" name="action" value="approve">Approve</button>
+<button type="submit" name="action" value="deny" formnovalidate>Deny</button>
+</p>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type consentPageData struct {
+	ClientName string
+	Scopes     []string
+	Params     map[string]string
+	Email      string
+	Error      string
+	Message    string
+}
+
+// renderConsentPage writes the consent page. It must never be framed, or
+// another site could trick the user into clicking Approve.
+func renderConsentPage(w http.ResponseWriter, code int, data consentPageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; frame-ancestors 'none'")
+	w.WriteHeader(code)
+	err := consentPage.Execute(w, data)
+	if err != nil {
+		log.Printf("Error rendering consent page: %v", err)
+	}
+}
+
+func renderAuthorizeError(w http.ResponseWriter, code int, message string) {
+	renderConsentPage(w, code, consentPageData{Message: message})
+}
+
+func (cfg *apiConfig) renderAuthorizeForm(w http.ResponseWriter, code int, req authorizeRequest, email, formError string) {
+	data := consentPageData{
+		ClientName: req.Client.Name,
+		Params: map[string]string{
+			"client_id":             req.Client.ID,
+			"redirect_uri":          req.RedirectURI,
+			"response_type":         "code",
+			"scope":                 strings.Join(req.Scopes, " "),
+			"state":                 req.State,
+			"code_challenge":        req.CodeChallenge,
+			"code_challenge_method": "S256",
+		},
+		Email: email,
+		Error: formError,
+	}
+	for _, scope := range req.Scopes {
+		data.Scopes = append(data.Scopes, scopeDescriptions[scope])
+	}
+	renderConsentPage(w, code, data)
+}
+
+// authorizeOAuthClient shows the consent page for an authorization request.
+func (cfg *apiConfig) authorizeOAuthClient(w http.ResponseWriter, r *http.Request) {
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.URL.Query())
+	if err != nil {
+		cfg.respondToAuthorizeError(w, r, req, err)
+		return
+	}
+	cfg.renderAuthorizeForm(w, http.StatusOK, req, "", "")
+}
+
+// approveOAuthClient handles the consent form. The user signs in on the
+// form itself, with the same throttling and two-factor checks as login, and
+// the client gets back a single-use authorization code.
+func (cfg *apiConfig) approveOAuthClient(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		renderAuthorizeError(w, http.StatusBadRequest, "Invalid form")
+		return
+	}
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.PostForm)
+	if err != nil {
+		cfg.respondToAuthorizeError(w, r, req, err)
+		return
+	}
+	if r.PostForm.Get("action") != "approve" {
+		redirectToClient(w, r, req, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	email := r.PostForm.Get("email")
+	userID, status, formError, err := cfg.checkConsentLogin(r, email, r.PostForm.Get("password"), r.PostForm.Get("code"))
+	if err != nil {
+		renderAuthorizeError(w, http.StatusInternalServerError, fmt.Sprintf("Error signing in: %v", err))
+		return
+	}
+	if formError != "" {
+		cfg.renderAuthorizeForm(w, status, req, email, formError)
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		renderAuthorizeError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating authorization code: %v", err))
+		return
+	}
+	err = cfg.queries.CreateOAuthAuthorizationCode(r.Context(), database.CreateOAuthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        userID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        req.Scopes,
+		CodeChallenge: req.CodeChallenge,
+	})
+	if err != nil {
+		renderAuthorizeError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving authorization code: %v", err))
+		return
+	}
+	redirectToClient(w, r, req, url.Values{"code": {code}})
+}
+
+// checkConsentLogin signs the user in from the consent form. A non-empty
+// formError is a problem to show on the form, with status as the response
+// code.
+func (cfg *apiConfig) checkConsentLogin(r *http.Request, email, password, code string) (userID uuid.UUID, status int, formError string, err error) {
+	throttle := cfg.passwordLoginThrottle(r, email)
+	retryAfter, err := cfg.beginLoginAttempt(r.Context(), throttle)
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	if retryAfter > 0 {
+		return uuid.Nil, http.StatusTooManyRequests, "Too many failed login attempts; try again later", nil
+	}
+	hashedPassword, err := cfg.queries.GetUserHashedPasswordByEmail(r.Context(), email)
+	if err == nil {
+		err = cfg.checkPasswordHash(password, hashedPassword)
+		if err != nil {
+			err = sql.ErrNoRows
+		}
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, http.StatusUnauthorized, "Incorrect email or password", nil
+	}
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	err = cfg.recordLoginSuccess(r.Context(), throttle)
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	user, err := cfg.queries.GetUserByEmail(r.Context(), email)
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	totp, err := cfg.queries.GetTOTP(r.Context(), user.ID)
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	if !totp.TotpEnabled {
+		return user.ID, 0, "", nil
+	}
+
+	if strings.TrimSpace(code) == "" {
+		return uuid.Nil, http.StatusUnauthorized, "Enter the code from your authenticator app or a recovery code", nil
+	}
+	throttle = cfg.twoFactorLoginThrottle(r, user.ID)
+	retryAfter, err = cfg.beginLoginAttempt(r.Context(), throttle)
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	if retryAfter > 0 {
+		return uuid.Nil, http.StatusTooManyRequests, "Too many failed login attempts; try again later", nil
+	}
+	err = cfg.checkSecondFactor(r.Context(), user.ID, totp, code)
+	if errors.Is(err, errInvalidSecondFactor) {
+		return uuid.Nil, http.StatusUnauthorized, "Incorrect two-factor code", nil
+	}
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	err = cfg.recordLoginSuccess(r.Context(), throttle)
+	if err != nil {
+		return uuid.Nil, 0, "", err
+	}
+	return user.ID, 0, "", nil
+}
+
+var errInvalidClient = errors.New("client authentication failed")
+
+// authenticateOAuthClient identifies the client calling a back-channel
+// endpoint, from HTTP Basic auth or the client_id and client_secret form
+// fields. Confidential clients must present their secret; public clients
+// only have an ID. r.ParseForm must have been called.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 section 2.3.1 has both form-encoded inside Basic auth.
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+	if clientID == "" {
+		return database.OauthClient{}, errInvalidClient
+	}
+	client, err := cfg.queries.GetOAuthClient(r.Context(), clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, errInvalidClient
+	}
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	if client.SecretHash.Valid {
+		if subtle.ConstantTimeCompare([]byte(auth.HashToken(secret)), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidClient
+		}
+	} else if secret != "" {
+		return database.OauthClient{}, errInvalidClient
+	}
+	return client, nil
+}
+
+// exchangeOAuthCode is the token endpoint. It only supports the
+// authorization_code grant; delegated access tokens aren't refreshable, so
+// clients send the user through authorization again when one expires.
+func (cfg *apiConfig) exchangeOAuthCode(w http.ResponseWriter, r *http.Request) {
+	type oauthTokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Scope       string `json:"scope"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form")
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if errors.Is(err, errInvalidClient) {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error getting client: %v", err))
+		return
+	}
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Only the authorization_code grant is supported")
+		return
+	}
+
+	codeHash := auth.HashToken(r.PostForm.Get("code"))
+	code, err := cfg.queries.UseOAuthAuthorizationCode(r.Context(), codeHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		// A code that was already spent may have been stolen, so anything
+		// issued from it is revoked (RFC 6749 section 4.1.2).
+		if err := cfg.queries.RevokeOAuthAccessTokensByCode(r.Context(), codeHash); err != nil {
+			respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error revoking tokens: %v", err))
+			return
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error getting authorization code: %v", err))
+		return
+	}
+	if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code was issued to another client or redirect_uri")
+		return
+	}
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match code_challenge")
+		return
+	}
+
+	tokenID := uuid.New()
+	err = cfg.queries.CreateOAuthAccessToken(r.Context(), database.CreateOAuthAccessTokenParams{
+		ID:                    tokenID,
+		ClientID:              client.ID,
+		UserID:                code.UserID,
+		Scopes:                code.Scopes,
+		AuthorizationCodeHash: codeHash,
+		ExpiresAt:             time.Now().UTC().Add(oauthAccessTokenLifetime),
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error saving access token: %v", err))
+		return
+	}
+	token, err := cfg.keys.MakeDelegatedJWT(code.UserID, client.ID, code.Scopes, tokenID, oauthAccessTokenLifetime)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error creating access token: %v", err))
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	respondWithJSON(w, http.StatusOK, oauthTokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oauthAccessTokenLifetime / time.Second),
+		Scope:       strings.Join(code.Scopes, " "),
+	})
+}
+
+// introspectOAuthToken reports whether an access token is active (RFC 7662).
+// Only confidential clients may ask, so the endpoint can't be used to probe
+// stolen tokens anonymously, and only about tokens issued to themselves:
+// first-party tokens and other clients' tokens always come back inactive.
+func (cfg *apiConfig) introspectOAuthToken(w http.ResponseWriter, r *http.Request) {
+	type introspectionResponse struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Subject   string `json:"sub,omitempty"`
+		ExpiresAt int64  `json:"exp,omitempty"`
+		IssuedAt  int64  `json:"iat,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form")
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if err == nil && !client.SecretHash.Valid {
+		err = errInvalidClient
+	}
+	if errors.Is(err, errInvalidClient) {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error getting client: %v", err))
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	claims, err := cfg.keys.ParseAccessToken(r.PostForm.Get("token"))
+	if err != nil || !claims.Delegated() || claims.ClientID != client.ID {
+		respondWithJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		respondWithJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+	issued, err := cfg.queries.GetOAuthAccessToken(r.Context(), tokenID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (issued.RevokedAt.Valid || issued.ClientID != client.ID)) {
+		respondWithJSON(w, http.StatusOK, introspectionResponse{Active: false})
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error getting access token: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, introspectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		Subject:   claims.Subject,
+		ExpiresAt: claims.ExpiresAt.Unix(),
+		IssuedAt:  claims.IssuedAt.Unix(),
+		TokenType: "Bearer",
+	})
+}
+
+// revokeOAuthToken lets a client revoke an access token it was issued
+// (RFC 7009). It succeeds even if the token is unknown or belongs to
+// another client, so it reveals nothing about the token.
+func (cfg *apiConfig) revokeOAuthToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "Invalid form")
+		return
+	}
+	client, err := cfg.authenticateOAuthClient(r)
+	if errors.Is(err, errInvalidClient) {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error getting client: %v", err))
+		return
+	}
+	claims, err := cfg.keys.ParseAccessToken(r.PostForm.Get("token"))
+	if err != nil || !claims.Delegated() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	err = cfg.queries.RevokeOAuthAccessToken(r.Context(), database.RevokeOAuthAccessTokenParams{
+		ID:       tokenID,
+		ClientID: client.ID,
+	})
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", fmt.Sprintf("Error revoking token: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
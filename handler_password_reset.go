@@ -0,0 +1,142 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// requestPasswordReset emails a single-use reset link. It answers 202 for
+// every address, known or not, so the response doesn't reveal whether an
+// account exists. Requests are limited per address and per client.
+func (cfg *apiConfig) requestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type resetRequestBody struct {
+		Email string `json:"email"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	body := resetRequestBody{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	// Throttled the same for every address, so a 429 doesn't reveal an
+	// account either.
+	retryAfter, err := cfg.beginLoginAttempt(r.Context(), cfg.passwordResetThrottle(r, body.Email))
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking reset requests: %v", err))
+		return
+	}
+	if retryAfter > 0 {
+		respondTooManyRequests(w, retryAfter, "Too many password reset requests; try again later")
+		return
+	}
+	user, err := cfg.queries.GetUserByEmail(r.Context(), body.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating reset token: %v", err))
+		return
+	}
+	err = cfg.queries.CreatePasswordResetToken(r.Context(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    user.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving reset token: %v", err))
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password for your Chirpy account.\n\n"+
+				"To choose a new password, open this link within the next hour:\n\n%s/app/reset-password.html?token=%s\n\n"+
+				"Your reset token is: %s\n\n"+
+				"If you didn't ask for this, you can ignore this email.\n",
+			cfg.appURL, url.QueryEscape(token), token,
+		),
+	}
+	cfg.sendMailAsync(msg)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// confirmPasswordReset sets a new password with a token from
+// requestPasswordReset and logs the account out of every session. Access
+// tokens that were already issued stay valid until they expire.
+func (cfg *apiConfig) confirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	type resetConfirmBody struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	body := resetConfirmBody{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	if body.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "Password is required")
+		return
+	}
+	hashedPassword, err := cfg.hashPassword(body.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error hashing password: %v", err))
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	userID, err := qtx.UsePasswordResetToken(r.Context(), auth.HashToken(body.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "Reset token is invalid, expired or already used")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error using reset token: %v", err))
+		return
+	}
+	err = qtx.SetUserPassword(r.Context(), database.SetUserPasswordParams{HashedPassword: hashedPassword, ID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating password: %v", err))
+		return
+	}
+	err = qtx.DeletePasswordResetTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error deleting reset tokens: %v", err))
+		return
+	}
+	err = qtx.RevokeUserRefreshTokens(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error revoking refresh tokens: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// originalChirpID resolves a rechirp to the chirp it amplifies, so rechirps,
+// quotes and replies always point at the original.
+func originalChirpID(c database.Chirp) uuid.UUID {
+	if c.RechirpOf.Valid {
+		return c.RechirpOf.UUID
+	}
+	return c.ID
+}
+
+func (cfg *apiConfig) createRechirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	blocked, err := cfg.mustVerifyEmail(r, userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	if blocked {
+		respondWithError(w, http.StatusForbidden, "Verify your email address before posting")
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing chirp ID: %v", err))
+		return
+	}
+	chirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	originalID := originalChirpID(chirp)
+
+	status := http.StatusCreated
+	res, err := cfg.queries.CreateRechirp(r.Context(), database.CreateRechirpParams{
+		UserID:    userID,
+		RechirpOf: originalID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		status = http.StatusOK
+		res, err = cfg.queries.GetRechirp(r.Context(), database.GetRechirpParams{
+			UserID:    userID,
+			RechirpOf: uuid.NullUUID{UUID: originalID, Valid: true},
+		})
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating rechirp: %v", err))
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), userID, []database.Chirp{res})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	respondWithJSON(w, status, resp[0])
+}
+
+func (cfg *apiConfig) deleteRechirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing chirp ID: %v", err))
+		return
+	}
+	chirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	err = cfg.queries.DeleteRechirp(r.Context(), database.DeleteRechirpParams{
+		UserID:    userID,
+		RechirpOf: uuid.NullUUID{UUID: originalChirpID(chirp), Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error deleting rechirp: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+func (cfg *apiConfig) updateChirp(w http.ResponseWriter, r *http.Request) {
+	type chirpBody struct {
+		Body string `json:"body"`
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing chirp ID: %v", err))
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	body := chirpBody{}
+	err = decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	if len(body.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+		return
+	}
+	cleanedChirp := cleanChirp(body.Body)
+
+	// The row lock keeps concurrent edits from saving the same previous body
+	// twice and losing one of the intermediate versions.
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	chirp, err := qtx.GetChirpForUpdate(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	if chirp.UserID != userID {
+		respondWithError(w, http.StatusForbidden, "User does not own chirp")
+		return
+	}
+	if chirp.RechirpOf.Valid {
+		respondWithError(w, http.StatusBadRequest, "Rechirps can't be edited")
+		return
+	}
+
+	if chirp.Body != cleanedChirp {
+		_, err = qtx.CreateChirpRevision(r.Context(), database.CreateChirpRevisionParams{
+			ChirpID: chirp.ID,
+			Body:    chirp.Body,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving revision: %v", err))
+			return
+		}
+		chirp, err = qtx.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+			Body: cleanedChirp,
+			ID:   chirp.ID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating chirp: %v", err))
+			return
+		}
+		err = saveChirpHashtags(r.Context(), qtx, chirp)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving hashtags: %v", err))
+			return
+		}
+		err = saveChirpMentions(r.Context(), qtx, chirp)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving mentions: %v", err))
+			return
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+
+	resp, err := cfg.chirpResponses(r.Context(), userID, []database.Chirp{chirp})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp[0])
+}
+
+func (cfg *apiConfig) getChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing chirp ID: %v", err))
+		return
+	}
+	_, err = cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	res, err := cfg.queries.GetChirpRevisions(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting revisions: %v", err))
+		return
+	}
+	if res == nil {
+		res = []database.ChirpRevision{}
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type searchQuery struct {
+	Text     string
+	AuthorID uuid.NullUUID
+	// AuthorUsername is set instead of AuthorID when from: names a user by
+	// username; the handler resolves it.
+	AuthorUsername string
+	Since          sql.NullTime
+	Until          sql.NullTime
+}
+
+// parseSearchQuery splits the from:, since: and until: operators out of q.
+// from: takes a username or a user ID.
+// Everything else is passed to websearch_to_tsquery, which handles "quoted
+// phrases", OR and -exclusions. Dates are YYYY-MM-DD or RFC 3339; a bare
+// until: date includes that whole day.
+func parseSearchQuery(q string) (searchQuery, error) {
+	res := searchQuery{}
+	terms := []string{}
+	for _, field := range strings.Fields(q) {
+		op, value, found := strings.Cut(field, ":")
+		if !found || value == "" {
+			terms = append(terms, field)
+			continue
+		}
+		switch strings.ToLower(op) {
+		case "from":
+			if id, err := uuid.Parse(value); err == nil {
+				res.AuthorID = uuid.NullUUID{UUID: id, Valid: true}
+				continue
+			}
+			res.AuthorUsername = strings.TrimPrefix(value, "@")
+		case "since":
+			t, _, err := parseSearchDate(value)
+			if err != nil {
+				return searchQuery{}, fmt.Errorf("invalid since: %w", err)
+			}
+			res.Since = sql.NullTime{Time: t, Valid: true}
+		case "until":
+			t, dateOnly, err := parseSearchDate(value)
+			if err != nil {
+				return searchQuery{}, fmt.Errorf("invalid until: %w", err)
+			}
+			if dateOnly {
+				t = t.AddDate(0, 0, 1)
+			}
+			res.Until = sql.NullTime{Time: t, Valid: true}
+		default:
+			terms = append(terms, field)
+		}
+	}
+	res.Text = strings.Join(terms, " ")
+	if res.Text == "" {
+		return searchQuery{}, errors.New("search text is required")
+	}
+	return res, nil
+}
+
+func parseSearchDate(s string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, true, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return t.UTC(), false, nil
+}
+
+func searchCursor(row database.SearchChirpsRow) pageCursor {
+	return pageCursor{CreatedAt: row.Chirp.CreatedAt, ID: row.Chirp.ID, Rank: row.Rank}
+}
+
+func (cfg *apiConfig) searchChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	query, err := parseSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing search query: %v", err))
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	if query.AuthorUsername != "" {
+		users, err := cfg.queries.GetUsersByUsernames(r.Context(), []string{strings.ToLower(query.AuthorUsername)})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+			return
+		}
+		if len(users) == 0 {
+			respondWithJSON(w, http.StatusOK, chirpsPage{Chirps: []chirpResponse{}})
+			return
+		}
+		query.AuthorID = uuid.NullUUID{UUID: users[0].ID, Valid: true}
+	}
+	res, err := cfg.queries.SearchChirps(r.Context(), database.SearchChirpsParams{
+		Query:          query.Text,
+		AuthorID:       query.AuthorID,
+		Since:          query.Since,
+		Until:          query.Until,
+		AfterRank:      page.afterRank(),
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		PageSize:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error searching chirps: %v", err))
+		return
+	}
+	rows, nextCursor := paginate(res, page, searchCursor)
+	chirps := make([]database.Chirp, 0, len(rows))
+	for _, row := range rows {
+		chirps = append(chirps, row.Chirp)
+	}
+	resp, err := cfg.chirpResponses(r.Context(), viewerID, chirps)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error searching chirps: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirpsPage{Chirps: resp, NextCursor: nextCursor})
+}
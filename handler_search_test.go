@@ -0,0 +1,89 @@
+package main
+
+import (
+	"database/sql"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	authorID := uuid.MustParse("3f1c6a52-7d2e-4b8a-9c1e-2f5d8b7a6c41")
+	tests := []struct {
+		name    string
+		q       string
+		want    searchQuery
+		wantErr bool
+	}{
+		{
+			name: "Plain text",
+			q:    `go "error handling" -java`,
+			want: searchQuery{Text: `go "error handling" -java`},
+		},
+		{
+			name: "From username",
+			q:    "from:@Alice gophers",
+			want: searchQuery{Text: "gophers", AuthorUsername: "Alice"},
+		},
+		{
+			name: "From user ID",
+			q:    "from:" + authorID.String() + " gophers",
+			want: searchQuery{Text: "gophers", AuthorID: uuid.NullUUID{UUID: authorID, Valid: true}},
+		},
+		{
+			name: "Operators are case-insensitive",
+			q:    "FROM:alice gophers",
+			want: searchQuery{Text: "gophers", AuthorUsername: "alice"},
+		},
+		{
+			name: "Until date includes the whole day",
+			q:    "since:2024-01-02 until:2024-01-05 gophers",
+			want: searchQuery{
+				Text:  "gophers",
+				Since: sql.NullTime{Time: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), Valid: true},
+				Until: sql.NullTime{Time: time.Date(2024, 1, 6, 0, 0, 0, 0, time.UTC), Valid: true},
+			},
+		},
+		{
+			name: "RFC 3339 times are converted to UTC",
+			q:    "until:2024-01-05T10:00:00+02:00 gophers",
+			want: searchQuery{
+				Text:  "gophers",
+				Until: sql.NullTime{Time: time.Date(2024, 1, 5, 8, 0, 0, 0, time.UTC), Valid: true},
+			},
+		},
+		{
+			name: "Unknown operators and empty values are search text",
+			q:    "https://go.dev from: café",
+			want: searchQuery{Text: "https://go.dev from: café"},
+		},
+		{
+			name:    "Invalid date",
+			q:       "since:yesterday gophers",
+			wantErr: true,
+		},
+		{
+			name:    "Operators only",
+			q:       "from:alice since:2024-01-02",
+			wantErr: true,
+		},
+		{
+			name:    "Empty",
+			q:       "   ",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSearchQuery(tt.q)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSearchQuery() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.want {
+				t.Errorf("parseSearchQuery() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
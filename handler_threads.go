@@ -0,0 +1,104 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+)
+
+// A thread shows at most maxThreadDepth levels of parents and of replies
+// around the chirp, and at most maxThreadReplies replies. Deeper replies are
+// reached by asking for the thread of a reply further down.
+const (
+	maxThreadDepth   = 50
+	maxThreadReplies = 500
+)
+
+type threadNode struct {
+	chirpResponse
+	Replies []*threadNode `json:"replies"`
+}
+
+type chirpThread struct {
+	Ancestors []chirpResponse `json:"ancestors"`
+	Chirp     *threadNode     `json:"chirp"`
+}
+
+// buildThread arranges the chirps of one conversation around the chirp with
+// the given ID: the chain of parents up to the conversation root, and the
+// tree of replies below it. Chirps are expected in (created_at, id) order so
+// replies come out oldest first.
+func buildThread(chirps []chirpResponse, chirpID uuid.UUID) (chirpThread, bool) {
+	nodes := make(map[uuid.UUID]*threadNode, len(chirps))
+	for _, c := range chirps {
+		nodes[c.ID] = &threadNode{chirpResponse: c, Replies: []*threadNode{}}
+	}
+	for _, c := range chirps {
+		if !c.InReplyTo.Valid {
+			continue
+		}
+		if parent, ok := nodes[c.InReplyTo.UUID]; ok {
+			parent.Replies = append(parent.Replies, nodes[c.ID])
+		}
+	}
+
+	node, ok := nodes[chirpID]
+	if !ok {
+		return chirpThread{}, false
+	}
+	ancestors := []chirpResponse{}
+	for parentID := node.InReplyTo; parentID.Valid; {
+		parent, ok := nodes[parentID.UUID]
+		if !ok {
+			break
+		}
+		ancestors = append([]chirpResponse{parent.chirpResponse}, ancestors...)
+		parentID = parent.InReplyTo
+	}
+	return chirpThread{Ancestors: ancestors, Chirp: node}, true
+}
+
+func (cfg *apiConfig) getChirpThread(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	viewerID, err := cfg.optionalUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing chirp ID: %v", err))
+		return
+	}
+	chirp, err := cfg.queries.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting chirp: %v", err))
+		return
+	}
+	ids, err := cfg.queries.GetThreadChirpIDs(r.Context(), database.GetThreadChirpIDsParams{
+		ChirpID:    chirp.ID,
+		MaxDepth:   maxThreadDepth,
+		MaxReplies: maxThreadReplies,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting conversation: %v", err))
+		return
+	}
+	conversation, err := cfg.queries.GetChirpsByIDs(r.Context(), ids)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting conversation: %v", err))
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), viewerID, conversation)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting conversation: %v", err))
+		return
+	}
+	thread, ok := buildThread(resp, chirpID)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, "Chirp not found in conversation")
+		return
+	}
+	respondWithJSON(w, http.StatusOK, thread)
+}
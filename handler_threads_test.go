@@ -0,0 +1,108 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"github.com/google/uuid"
+	"slices"
+	"testing"
+)
+
+func TestBuildThread(t *testing.T) {
+	a, b, c, d, e := uuid.New(), uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	chirp := func(id uuid.UUID, parent uuid.UUID) chirpResponse {
+		return chirpResponse{Chirp: database.Chirp{
+			ID:        id,
+			InReplyTo: uuid.NullUUID{UUID: parent, Valid: parent != uuid.Nil},
+		}}
+	}
+
+	tests := []struct {
+		name          string
+		chirps        []chirpResponse
+		chirpID       uuid.UUID
+		wantAncestors []uuid.UUID
+		wantReplies   []uuid.UUID
+		wantNested    map[uuid.UUID][]uuid.UUID
+	}{
+		{
+			name:          "Ancestors and replies",
+			chirps:        []chirpResponse{chirp(a, uuid.Nil), chirp(b, a), chirp(c, b), chirp(d, a), chirp(e, c)},
+			chirpID:       b,
+			wantAncestors: []uuid.UUID{a},
+			wantReplies:   []uuid.UUID{c},
+			wantNested:    map[uuid.UUID][]uuid.UUID{c: {e}},
+		},
+		{
+			name:          "Replies move up to the grandparent after a delete",
+			chirps:        []chirpResponse{chirp(a, uuid.Nil), chirp(c, a), chirp(d, a), chirp(e, c)},
+			chirpID:       a,
+			wantAncestors: []uuid.UUID{},
+			wantReplies:   []uuid.UUID{c, d},
+			wantNested:    map[uuid.UUID][]uuid.UUID{c: {e}},
+		},
+		{
+			name:          "Reparented reply keeps its ancestors",
+			chirps:        []chirpResponse{chirp(a, uuid.Nil), chirp(c, a), chirp(e, c)},
+			chirpID:       e,
+			wantAncestors: []uuid.UUID{a, c},
+			wantReplies:   []uuid.UUID{},
+		},
+		{
+			name:          "Replies become roots after the root is deleted",
+			chirps:        []chirpResponse{chirp(b, uuid.Nil), chirp(c, b), chirp(d, uuid.Nil)},
+			chirpID:       b,
+			wantAncestors: []uuid.UUID{},
+			wantReplies:   []uuid.UUID{c},
+		},
+		{
+			name:          "Ancestors stop at a parent that wasn't loaded",
+			chirps:        []chirpResponse{chirp(b, a), chirp(c, b)},
+			chirpID:       c,
+			wantAncestors: []uuid.UUID{b},
+			wantReplies:   []uuid.UUID{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thread, ok := buildThread(tt.chirps, tt.chirpID)
+			if !ok {
+				t.Fatalf("buildThread() found no chirp %v", tt.chirpID)
+			}
+			if thread.Chirp.ID != tt.chirpID {
+				t.Errorf("buildThread() chirp = %v, want %v", thread.Chirp.ID, tt.chirpID)
+			}
+			ancestors := []uuid.UUID{}
+			for _, c := range thread.Ancestors {
+				ancestors = append(ancestors, c.ID)
+			}
+			if !slices.Equal(ancestors, tt.wantAncestors) {
+				t.Errorf("buildThread() ancestors = %v, want %v", ancestors, tt.wantAncestors)
+			}
+			if got := threadReplyIDs(thread.Chirp); !slices.Equal(got, tt.wantReplies) {
+				t.Errorf("buildThread() replies = %v, want %v", got, tt.wantReplies)
+			}
+			for _, reply := range thread.Chirp.Replies {
+				want, ok := tt.wantNested[reply.ID]
+				if !ok {
+					want = []uuid.UUID{}
+				}
+				if got := threadReplyIDs(reply); !slices.Equal(got, want) {
+					t.Errorf("buildThread() replies to %v = %v, want %v", reply.ID, got, want)
+				}
+			}
+		})
+	}
+
+	if _, ok := buildThread([]chirpResponse{chirp(a, uuid.Nil)}, b); ok {
+		t.Errorf("buildThread() found chirp %v, which isn't in the conversation", b)
+	}
+}
+
+func threadReplyIDs(node *threadNode) []uuid.UUID {
+	ids := []uuid.UUID{}
+	for _, reply := range node.Replies {
+		ids = append(ids, reply.ID)
+	}
+	return ids
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+const (
+	totpIssuer         = "Chirpy"
+	recoveryCodeCount  = 10
+	twoFactorChallenge = 5 * time.Minute
+)
+
+var totpCodePattern = regexp.MustCompile(`^\s*[0-9]{6}\s*$`)
+
+var errInvalidSecondFactor = errors.New("incorrect two-factor code")
+
+type twoFactorBody struct {
+	// Code is either a code from the authenticator app or a recovery code.
+	Code string `json:"code"`
+}
+
+// checkSecondFactor accepts a current TOTP code or an unused recovery code
+// and spends it, so neither works a second time.
+func (cfg *apiConfig) checkSecondFactor(ctx context.Context, userID uuid.UUID, totp database.GetTOTPRow, code string) error {
+	if totpCodePattern.MatchString(code) {
+		step, err := auth.ValidateTOTP(code, totp.TotpSecret.String, time.Now(), totp.TotpLastStep)
+		if errors.Is(err, auth.ErrInvalidTOTP) {
+			return errInvalidSecondFactor
+		}
+		if err != nil {
+			return err
+		}
+		used, err := cfg.queries.UseTOTPStep(ctx, database.UseTOTPStepParams{TotpLastStep: step, ID: userID})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+	used, err := cfg.queries.UseRecoveryCode(ctx, database.UseRecoveryCodeParams{
+		UserID:   userID,
+		CodeHash: auth.HashToken(auth.NormalizeRecoveryCode(code)),
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+func (cfg *apiConfig) respondWithTwoFactorChallenge(w http.ResponseWriter, userID uuid.UUID) {
+	type challengeResponse struct {
+		TwoFactorRequired bool   `json:"two_factor_required"`
+		ChallengeToken    string `json:"challenge_token"`
+	}
+	token, err := cfg.keys.MakeChallengeJWT(userID, twoFactorChallenge)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating challenge token: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, challengeResponse{TwoFactorRequired: true, ChallengeToken: token})
+}
+
+// loginTwoFactor is the second step of a login for users with two-factor
+// authentication: it trades the challenge token from login plus a code for
+// the usual access and refresh tokens.
+func (cfg *apiConfig) loginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	type loginTwoFactorBody struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"`
+		DeviceName     string `json:"device_name"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	body := loginTwoFactorBody{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	userID, err := cfg.keys.ValidateChallengeJWT(body.ChallengeToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error validating challenge token: %v", err))
+		return
+	}
+	throttle := cfg.twoFactorLoginThrottle(r, userID)
+	retryAfter, err := cfg.beginLoginAttempt(r.Context(), throttle)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking login attempts: %v", err))
+		return
+	}
+	if retryAfter > 0 {
+		respondTooManyAttempts(w, retryAfter)
+		return
+	}
+	totp, err := cfg.queries.GetTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	if !totp.TotpEnabled {
+		respondWithError(w, http.StatusUnauthorized, "Two-factor authentication is not enabled")
+		return
+	}
+	err = cfg.checkSecondFactor(r.Context(), userID, totp, body.Code)
+	if errors.Is(err, errInvalidSecondFactor) {
+		respondWithError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking two-factor code: %v", err))
+		return
+	}
+	err = cfg.recordLoginSuccess(r.Context(), throttle)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error recording login attempt: %v", err))
+		return
+	}
+	user, err := cfg.queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	cfg.respondWithLoginTokens(w, r, database.GetUserByEmailRow(user), body.DeviceName)
+}
+
+// enrollTOTP starts two-factor enrollment with a fresh secret. It isn't
+// required at login until confirmed through verifyTOTP.
+func (cfg *apiConfig) enrollTOTP(w http.ResponseWriter, r *http.Request) {
+	type enrollResponse struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	user, err := cfg.queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	totp, err := cfg.queries.GetTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	if totp.TotpEnabled {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating TOTP secret: %v", err))
+		return
+	}
+	err = cfg.queries.SetTOTPSecret(r.Context(), database.SetTOTPSecretParams{
+		TotpSecret: sql.NullString{String: secret, Valid: true},
+		ID:         userID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving TOTP secret: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, enrollResponse{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI(totpIssuer, user.Email, secret),
+	})
+}
+
+// verifyTOTP finishes enrollment once the user proves their app produces
+// the right codes, and hands out recovery codes. They are only ever shown
+// here.
+func (cfg *apiConfig) verifyTOTP(w http.ResponseWriter, r *http.Request) {
+	type verifyResponse struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	body := twoFactorBody{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	totp, err := cfg.queries.GetTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	if totp.TotpEnabled {
+		respondWithError(w, http.StatusConflict, "Two-factor authentication is already enabled")
+		return
+	}
+	if !totp.TotpSecret.Valid {
+		respondWithError(w, http.StatusBadRequest, "Two-factor enrollment has not been started")
+		return
+	}
+	step, err := auth.ValidateTOTP(body.Code, totp.TotpSecret.String, time.Now(), 0)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, errInvalidSecondFactor.Error())
+		return
+	}
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating recovery codes: %v", err))
+		return
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashToken(auth.NormalizeRecoveryCode(code)))
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	err = qtx.EnableTOTP(r.Context(), database.EnableTOTPParams{TotpLastStep: step, ID: userID})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error enabling two-factor authentication: %v", err))
+		return
+	}
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving recovery codes: %v", err))
+		return
+	}
+	err = qtx.CreateRecoveryCodes(r.Context(), database.CreateRecoveryCodesParams{UserID: userID, CodeHashes: hashes})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving recovery codes: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, verifyResponse{RecoveryCodes: codes})
+}
+
+// disableTOTP turns two-factor authentication off. It takes a code as well
+// as the access token, so a stolen access token alone can't remove it.
+func (cfg *apiConfig) disableTOTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, err := cfg.authenticatedUserID(r)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Couldn't validate user: %v", err))
+		return
+	}
+	body := twoFactorBody{}
+	decoder := json.NewDecoder(r.Body)
+	err = decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	// The code is guessed the same way as at login, so it shares the
+	// login's attempt limit.
+	throttle := cfg.twoFactorLoginThrottle(r, userID)
+	retryAfter, err := cfg.beginLoginAttempt(r.Context(), throttle)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking login attempts: %v", err))
+		return
+	}
+	if retryAfter > 0 {
+		respondTooManyAttempts(w, retryAfter)
+		return
+	}
+	totp, err := cfg.queries.GetTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	if !totp.TotpEnabled {
+		respondWithError(w, http.StatusBadRequest, "Two-factor authentication is not enabled")
+		return
+	}
+	err = cfg.checkSecondFactor(r.Context(), userID, totp, body.Code)
+	if errors.Is(err, errInvalidSecondFactor) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking two-factor code: %v", err))
+		return
+	}
+	err = cfg.recordLoginSuccess(r.Context(), throttle)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error recording login attempt: %v", err))
+		return
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	err = qtx.DisableTOTP(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error disabling two-factor authentication: %v", err))
+		return
+	}
+	err = qtx.DeleteRecoveryCodes(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error deleting recovery codes: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -3,16 +3,63 @@ package main
 import (
 	"chirpy/internal/auth"
 	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"chirpy/internal/webhook"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/google/uuid"
+	"log"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 type userBody struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email       string  `json:"email"`
+	Password    string  `json:"password"`
+	Username    string  `json:"username"`
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	// DeviceName labels the session started by login.
+	DeviceName string `json:"device_name"`
+}
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+)
+
+// usernamePattern is also what @mentions in chirps match, so every username
+// can be mentioned.
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_]{3,30}$`)
+
+// optionalUsername validates a username from a request body. An empty
+// username means the field was left out.
+func optionalUsername(username string) (sql.NullString, error) {
+	if username == "" {
+		return sql.NullString{}, nil
+	}
+	if !usernamePattern.MatchString(username) {
+		return sql.NullString{}, errors.New("username must be 3-30 letters, digits or underscores")
+	}
+	return sql.NullString{String: username, Valid: true}, nil
+}
+
+// optionalProfileText validates a display name or bio from a request body.
+// A nil value means the field was left out.
+func optionalProfileText(field string, value *string, maxLength int) (sql.NullString, error) {
+	if value == nil {
+		return sql.NullString{}, nil
+	}
+	text := strings.TrimSpace(*value)
+	if utf8.RuneCountInString(text) > maxLength {
+		return sql.NullString{}, fmt.Errorf("%s must be at most %d characters", field, maxLength)
+	}
+	return sql.NullString{String: text, Valid: true}, nil
 }
 
 type tokenResponse struct {
@@ -30,19 +77,74 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
 		return
 	}
-	hashedPassword, err := auth.HashPassword(body.Password)
+	username, err := optionalUsername(body.Username)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	displayName, err := optionalProfileText("display_name", body.DisplayName, maxDisplayNameLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	bio, err := optionalProfileText("bio", body.Bio, maxBioLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	hashedPassword, err := cfg.hashPassword(body.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error hashing password: %v", err))
 		return
 	}
-	res, err := cfg.queries.CreateUser(
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	res, err := qtx.CreateUser(
 		r.Context(),
-		database.CreateUserParams{Email: body.Email, HashedPassword: hashedPassword},
+		database.CreateUserParams{
+			Email:          body.Email,
+			HashedPassword: hashedPassword,
+			Username:       username,
+			DisplayName:    displayName.String,
+			Bio:            bio.String,
+		},
 	)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or username is already taken")
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating user: %v", err))
 		return
 	}
+	err = enqueueWebhookEvent(r.Context(), qtx, webhook.EventUserCreated, webhookUser{
+		ID:          res.ID,
+		Username:    res.Username,
+		IsChirpyRed: res.IsChirpyRed,
+		CreatedAt:   res.CreatedAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error queueing webhooks: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+	// The account exists either way; if this fails the user can ask for
+	// another verification email.
+	msg, err := cfg.createEmailVerification(r.Context(), cfg.queries, res.ID, res.Email)
+	if err != nil {
+		log.Printf("Error creating email verification for user %s: %v", res.ID, err)
+	} else {
+		cfg.sendMailAsync(msg)
+	}
 	respondWithJSON(w, http.StatusCreated, res)
 }
 
@@ -55,27 +157,53 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
 		return
 	}
+	// Count the attempt before doing any work, bcrypt especially.
+	throttle := cfg.passwordLoginThrottle(r, body.Email)
+	retryAfter, err := cfg.beginLoginAttempt(r.Context(), throttle)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error checking login attempts: %v", err))
+		return
+	}
+	if retryAfter > 0 {
+		respondTooManyAttempts(w, retryAfter)
+		return
+	}
 	hashedPassword, err := cfg.queries.GetUserHashedPasswordByEmail(r.Context(), body.Email)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, fmt.Sprintf("Error getting user: %v", err))
 		return
 	}
-	err = auth.CheckPasswordHash(body.Password, hashedPassword)
+	err = cfg.checkPasswordHash(body.Password, hashedPassword)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "incorrect email or password")
 		return
 	}
+	err = cfg.recordLoginSuccess(r.Context(), throttle)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error recording login attempt: %v", err))
+		return
+	}
 	user, err := cfg.queries.GetUserByEmail(r.Context(), body.Email)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
 		return
 	}
+	totp, err := cfg.queries.GetTOTP(r.Context(), user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
+	if totp.TotpEnabled {
+		cfg.respondWithTwoFactorChallenge(w, user.ID)
+		return
+	}
+	cfg.respondWithLoginTokens(w, r, user, body.DeviceName)
+}
+
+// respondWithLoginTokens finishes a login by starting a new session.
+func (cfg *apiConfig) respondWithLoginTokens(w http.ResponseWriter, r *http.Request, user database.GetUserByEmailRow, deviceName string) {
 	expirationTime := time.Hour
-	accessToken, err := auth.MakeJWT(
-		user.ID,
-		cfg.secretToken,
-		expirationTime,
-	)
+	accessToken, err := cfg.keys.MakeJWT(user.ID, user.Role, expirationTime)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating access token: %v", err))
 		return
@@ -88,7 +216,14 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 	}
 	_, err = cfg.queries.CreateRefreshToken(
 		r.Context(),
-		database.CreateRefreshTokenParams{Token: refreshToken, UserID: user.ID},
+		database.CreateRefreshTokenParams{
+			Token:      refreshToken,
+			UserID:     user.ID,
+			FamilyID:   uuid.New(),
+			DeviceName: deviceName,
+			UserAgent:  r.UserAgent(),
+			Ip:         cfg.clientIP(r),
+		},
 	)
 	if err != nil {
 		respondWithError(
@@ -106,9 +241,15 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// refreshLoginToken exchanges a refresh token for a new access token and a
+// new refresh token. Each refresh token can be used once; tokens issued from
+// the same login form a family. Presenting a token that was already rotated
+// means it leaked, so the whole family is revoked and the user has to log in
+// again.
 func (cfg *apiConfig) refreshLoginToken(w http.ResponseWriter, r *http.Request) {
 	type refreshTokenBody struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
 	w.Header().Set("Content-Type", "application/json")
 	bearerToken, err := auth.GetBearerToken(r.Header)
@@ -121,21 +262,84 @@ func (cfg *apiConfig) refreshLoginToken(w http.ResponseWriter, r *http.Request)
 		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error getting refresh token: %v", err))
 		return
 	}
-	if time.Now().UTC().After(res.ExpiresAt) || res.RevokedAt.Time != (time.Time{}) {
+	if res.ReplacedBy.Valid {
+		cfg.revokeRefreshTokenFamily(w, r, res.FamilyID)
+		return
+	}
+	if time.Now().UTC().After(res.ExpiresAt) || res.RevokedAt.Valid {
 		respondWithError(w, http.StatusUnauthorized, "Refresh token expired or revoked")
 		return
 	}
+
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating refresh token: %v", err))
+		return
+	}
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	rotated, err := qtx.RotateRefreshToken(r.Context(), database.RotateRefreshTokenParams{
+		ReplacedBy: sql.NullString{String: refreshToken, Valid: true},
+		Token:      res.Token,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error rotating refresh token: %v", err))
+		return
+	}
+	if rotated == 0 {
+		// Another request rotated or revoked this token after we read it.
+		tx.Rollback()
+		cfg.revokeRefreshTokenFamily(w, r, res.FamilyID)
+		return
+	}
+	_, err = qtx.CreateRefreshToken(r.Context(), database.CreateRefreshTokenParams{
+		Token:      refreshToken,
+		UserID:     res.UserID,
+		FamilyID:   res.FamilyID,
+		DeviceName: res.DeviceName,
+		UserAgent:  r.UserAgent(),
+		Ip:         cfg.clientIP(r),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating refresh token in db: %v", err))
+		return
+	}
+	err = tx.Commit()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
+		return
+	}
+
+	// Read the role again rather than trusting the old access token, so a
+	// role change takes effect at the next refresh.
+	user, err := cfg.queries.GetUserByID(r.Context(), res.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
+	}
 	expirationTime := time.Hour
-	accessToken, err := auth.MakeJWT(
-		res.UserID,
-		cfg.secretToken,
-		expirationTime,
-	)
+	accessToken, err := cfg.keys.MakeJWT(res.UserID, user.Role, expirationTime)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating access token: %v", err))
 		return
 	}
-	respondWithJSON(w, http.StatusOK, refreshTokenBody{Token: accessToken})
+	respondWithJSON(w, http.StatusOK, refreshTokenBody{Token: accessToken, RefreshToken: refreshToken})
+}
+
+// revokeRefreshTokenFamily handles a reused refresh token by revoking every
+// token descended from the same login.
+func (cfg *apiConfig) revokeRefreshTokenFamily(w http.ResponseWriter, r *http.Request, familyID uuid.UUID) {
+	err := cfg.queries.RevokeRefreshTokenFamily(r.Context(), familyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error revoking refresh tokens: %v", err))
+		return
+	}
+	respondWithError(w, http.StatusUnauthorized, "Refresh token reuse detected; all sessions from this login were revoked")
 }
 
 func (cfg *apiConfig) revokeLoginToken(w http.ResponseWriter, r *http.Request) {
@@ -156,12 +360,7 @@ func (cfg *apiConfig) revokeLoginToken(w http.ResponseWriter, r *http.Request) {
 func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	body := userBody{}
-	bearerToken, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error getting bearer token: %v", err))
-		return
-	}
-	userID, err := auth.ValidateJWT(bearerToken, cfg.secretToken)
+	userID, err := cfg.authenticatedUserID(r)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error validating JWT: %v", err))
 		return
@@ -173,59 +372,112 @@ func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
 		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
 		return
 	}
-	hashedPassword, err := auth.HashPassword(body.Password)
+	username, err := optionalUsername(body.Username)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error hashing password: %v", err))
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	res, err := cfg.queries.UpdateUser(
-		r.Context(),
-		database.UpdateUserParams{Email: body.Email, HashedPassword: hashedPassword, ID: userID},
-	)
+	displayName, err := optionalProfileText("display_name", body.DisplayName, maxDisplayNameLength)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating user: %v", err))
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
-	respondWithJSON(w, http.StatusOK, res)
-}
-
-func (cfg *apiConfig) upgradeUserWebhookHandler(w http.ResponseWriter, r *http.Request) {
-	type webhookBody struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
+	bio, err := optionalProfileText("bio", body.Bio, maxBioLength)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	apiKey, err := auth.GetAPIKey(r.Header)
-	if err != nil || apiKey != cfg.polkaKey {
-		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error getting API key: %v", err))
+	// Fields left out of the request keep their current values.
+	hashedPassword := sql.NullString{}
+	if body.Password != "" {
+		hash, err := cfg.hashPassword(body.Password)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error hashing password: %v", err))
+			return
+		}
+		hashedPassword = sql.NullString{String: hash, Valid: true}
+	}
+	user, err := cfg.queries.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
 		return
 	}
-	body := webhookBody{}
-	decoder := json.NewDecoder(r.Body)
-	err = decoder.Decode(&body)
+	// A new email address only replaces the current one once it's verified.
+	emailChanged := body.Email != "" && body.Email != user.Email
+	if emailChanged {
+		_, err = cfg.queries.GetUserByEmail(r.Context(), body.Email)
+		if err == nil {
+			respondWithError(w, http.StatusConflict, "Email or username is already taken")
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+			return
+		}
+	}
+
+	tx, err := cfg.db.BeginTx(r.Context(), nil)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error starting transaction: %v", err))
 		return
 	}
-	if body.Event != "user.upgraded" {
-		w.WriteHeader(http.StatusNoContent)
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	var verification mailer.Message
+	if emailChanged {
+		err = qtx.SetPendingEmail(r.Context(), database.SetPendingEmailParams{
+			PendingEmail: sql.NullString{String: body.Email, Valid: true},
+			ID:           userID,
+		})
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating user: %v", err))
+			return
+		}
+		verification, err = cfg.createEmailVerification(r.Context(), qtx, userID, body.Email)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating email verification: %v", err))
+			return
+		}
+	}
+	res, err := qtx.UpdateUser(
+		r.Context(),
+		database.UpdateUserParams{
+			HashedPassword: hashedPassword,
+			Username:       username,
+			DisplayName:    displayName,
+			Bio:            bio,
+			ID:             userID,
+		},
+	)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "Email or username is already taken")
 		return
 	}
-	userID, err := uuid.Parse(body.Data.UserID)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error parsing user ID: %v", err))
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error updating user: %v", err))
 		return
 	}
-	user, err := cfg.queries.UpgradeUser(r.Context(), database.UpgradeUserParams{ID: userID, IsChirpyRed: true})
+	err = tx.Commit()
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error upgrading user: %v", err))
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error committing transaction: %v", err))
 		return
 	}
-	if user != (database.UpgradeUserRow{}) {
-		w.WriteHeader(http.StatusNoContent)
-	} else {
+	if emailChanged {
+		cfg.sendMailAsync(verification)
+	}
+	respondWithJSON(w, http.StatusOK, res)
+}
+
+func (cfg *apiConfig) getUserProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	profile, err := cfg.queries.GetUserProfile(r.Context(), r.PathValue("username"))
+	if errors.Is(err, sql.ErrNoRows) {
 		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting user: %v", err))
+		return
 	}
+	respondWithJSON(w, http.StatusOK, profile)
 }
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/webhook"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"net/http"
+	"net/url"
+	"slices"
+	"time"
+)
+
+var webhookDeliveryStatuses = []string{"pending", "delivered", "dead"}
+
+type webhookEndpointResponse struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+	// Secret is only shown when the endpoint is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// createWebhookEndpoint subscribes an HTTPS URL to some event types. The
+// response carries the secret the endpoint uses to check signatures.
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	type endpointBody struct {
+		URL        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	body := endpointBody{}
+	decoder := json.NewDecoder(r.Body)
+	err := decoder.Decode(&body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	u, err := url.Parse(body.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.User != nil {
+		respondWithError(w, http.StatusBadRequest, "url must be an https URL")
+		return
+	}
+	if len(body.EventTypes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "At least one event type is required")
+		return
+	}
+	for _, t := range body.EventTypes {
+		if !slices.Contains(webhook.EventTypes, t) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown event type %q", t))
+			return
+		}
+	}
+	slices.Sort(body.EventTypes)
+	body.EventTypes = slices.Compact(body.EventTypes)
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error creating secret: %v", err))
+		return
+	}
+	endpoint, err := cfg.queries.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		Url:        u.String(),
+		Secret:     "whsec_" + secret,
+		EventTypes: body.EventTypes,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error saving webhook endpoint: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusCreated, webhookEndpointResponse{
+		ID:         endpoint.ID,
+		URL:        endpoint.Url,
+		EventTypes: endpoint.EventTypes,
+		CreatedAt:  endpoint.CreatedAt,
+		Secret:     endpoint.Secret,
+	})
+}
+
+func (cfg *apiConfig) getWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	endpoints, err := cfg.queries.GetWebhookEndpoints(r.Context())
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting webhook endpoints: %v", err))
+		return
+	}
+	resp := []webhookEndpointResponse{}
+	for _, e := range endpoints {
+		resp = append(resp, webhookEndpointResponse{
+			ID:         e.ID,
+			URL:        e.Url,
+			EventTypes: e.EventTypes,
+			CreatedAt:  e.CreatedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// deleteWebhookEndpoint unsubscribes an endpoint and drops its queued and
+// past deliveries.
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing endpoint ID: %v", err))
+		return
+	}
+	deleted, err := cfg.queries.DeleteWebhookEndpoint(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error deleting webhook endpoint: %v", err))
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Webhook endpoint not found")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type webhookDeliveryResponse struct {
+	ID            uuid.UUID       `json:"id"`
+	EventID       uuid.UUID       `json:"event_id"`
+	EventType     string          `json:"event_type"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+func webhookDeliveryCursor(d database.WebhookDelivery) pageCursor {
+	return pageCursor{CreatedAt: d.CreatedAt, ID: d.ID}
+}
+
+// getWebhookDeliveries lists an endpoint's deliveries, newest first.
+// ?status=dead shows the dead-lettered ones.
+func (cfg *apiConfig) getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	type webhookDeliveriesPage struct {
+		Deliveries []webhookDeliveryResponse `json:"deliveries"`
+		NextCursor string                    `json:"next_cursor,omitempty"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	endpointID, err := uuid.Parse(r.PathValue("endpointID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing endpoint ID: %v", err))
+		return
+	}
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	status := sql.NullString{}
+	if s := r.URL.Query().Get("status"); s != "" {
+		if !slices.Contains(webhookDeliveryStatuses, s) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown status %q", s))
+			return
+		}
+		status = sql.NullString{String: s, Valid: true}
+	}
+	res, err := cfg.queries.GetWebhookDeliveries(r.Context(), database.GetWebhookDeliveriesParams{
+		EndpointID:     endpointID,
+		Status:         status,
+		AfterCreatedAt: page.afterCreatedAt(),
+		AfterID:        page.afterID(),
+		PageSize:       page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting webhook deliveries: %v", err))
+		return
+	}
+	deliveries, nextCursor := paginate(res, page, webhookDeliveryCursor)
+	resp := webhookDeliveriesPage{Deliveries: []webhookDeliveryResponse{}, NextCursor: nextCursor}
+	for _, d := range deliveries {
+		delivery := webhookDeliveryResponse{
+			ID:          d.ID,
+			EventID:     d.EventID,
+			EventType:   d.EventType,
+			Status:      d.Status,
+			Attempts:    d.Attempts,
+			LastError:   d.LastError.String,
+			CreatedAt:   d.CreatedAt,
+			DeliveredAt: nullTimePtr(d.DeliveredAt),
+			Payload:     json.RawMessage(d.Payload),
+		}
+		if d.Status == "pending" {
+			delivery.NextAttemptAt = &d.NextAttemptAt
+		}
+		resp.Deliveries = append(resp.Deliveries, delivery)
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+// retryWebhookDelivery puts a dead-lettered delivery back in the queue,
+// e.g. once the endpoint is fixed.
+func (cfg *apiConfig) retryWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := uuid.Parse(r.PathValue("deliveryID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing delivery ID: %v", err))
+		return
+	}
+	retried, err := cfg.queries.RetryWebhookDelivery(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error retrying webhook delivery: %v", err))
+		return
+	}
+	if retried == 0 {
+		respondWithError(w, http.StatusNotFound, "No dead-lettered delivery with that ID")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
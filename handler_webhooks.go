@@ -0,0 +1,318 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"chirpy/internal/webhook"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+)
+
+const (
+	// polkaWebhookTolerance is how far a delivery's signing time may be
+	// from ours, which bounds how long a captured delivery stays valid.
+	polkaWebhookTolerance = 5 * time.Minute
+	maxWebhookBodyBytes   = 1 << 20
+)
+
+// Statuses of a stored webhook event. Received events haven't been
+// attempted yet; failed ones are retried when redelivered or replayed.
+const (
+	webhookStatusReceived  = "received"
+	webhookStatusProcessed = "processed"
+	webhookStatusIgnored   = "ignored"
+	webhookStatusFailed    = "failed"
+)
+
+var webhookStatuses = []string{webhookStatusReceived, webhookStatusProcessed, webhookStatusIgnored, webhookStatusFailed}
+
+var errWebhookUserNotFound = errors.New("user not found")
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID string `json:"user_id"`
+	} `json:"data"`
+}
+
+// applyPolkaEvent makes the change a Polka event calls for and returns the
+// status to record it with. Events only ever set is_chirpy_red, so applying
+// one twice does no harm.
+func applyPolkaEvent(ctx context.Context, q *database.Queries, payload string) (string, error) {
+	event := polkaEvent{}
+	err := json.Unmarshal([]byte(payload), &event)
+	if err != nil {
+		return "", fmt.Errorf("decoding event: %w", err)
+	}
+	var isChirpyRed bool
+	var eventType string
+	switch event.Event {
+	case "user.upgraded":
+		isChirpyRed, eventType = true, webhook.EventUserUpgraded
+	case "user.downgraded", "subscription.expired":
+		isChirpyRed, eventType = false, webhook.EventUserDowngraded
+	default:
+		return webhookStatusIgnored, nil
+	}
+	userID, err := uuid.Parse(event.Data.UserID)
+	if err != nil {
+		return "", fmt.Errorf("parsing user ID: %w", err)
+	}
+	user, err := q.UpgradeUser(ctx, database.UpgradeUserParams{ID: userID, IsChirpyRed: isChirpyRed})
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errWebhookUserNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("updating user: %w", err)
+	}
+	err = enqueueWebhookEvent(ctx, q, eventType, webhookUser{
+		ID:          user.ID,
+		Username:    user.Username,
+		IsChirpyRed: user.IsChirpyRed,
+		CreatedAt:   user.CreatedAt,
+	})
+	if err != nil {
+		return "", fmt.Errorf("queueing webhooks: %w", err)
+	}
+	return webhookStatusProcessed, nil
+}
+
+// webhookOutcomeDuplicate is reported for an event that was already
+// handled, so nothing was done.
+const webhookOutcomeDuplicate = "duplicate"
+
+// processWebhookEvent applies a stored event, records the outcome and
+// returns it: the status stored, or webhookOutcomeDuplicate. Events that
+// were already handled are skipped unless force is set, which is what makes
+// redeliveries safe; admins force a replay. The event row is locked
+// throughout, so two deliveries of one event can't both apply it.
+func (cfg *apiConfig) processWebhookEvent(ctx context.Context, source, eventID string, force bool) (string, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+	event, err := qtx.LockWebhookEvent(ctx, database.LockWebhookEventParams{Source: source, EventID: eventID})
+	if err != nil {
+		return "", err
+	}
+	if !force && (event.Status == webhookStatusProcessed || event.Status == webhookStatusIgnored) {
+		return webhookOutcomeDuplicate, nil
+	}
+
+	var status string
+	var applyErr error
+	switch source {
+	case "polka":
+		status, applyErr = applyPolkaEvent(ctx, qtx, event.Payload)
+	default:
+		applyErr = fmt.Errorf("no handler for %s events", source)
+	}
+	if applyErr != nil {
+		tx.Rollback()
+		err = cfg.queries.MarkWebhookEventFailed(ctx, database.MarkWebhookEventFailedParams{
+			Error: sql.NullString{String: applyErr.Error(), Valid: true},
+			ID:    event.ID,
+		})
+		if err != nil {
+			return "", err
+		}
+		return webhookStatusFailed, applyErr
+	}
+	err = qtx.MarkWebhookEventDone(ctx, database.MarkWebhookEventDoneParams{Status: status, ID: event.ID})
+	if err != nil {
+		return "", err
+	}
+	return status, tx.Commit()
+}
+
+// upgradeUserWebhookHandler handles events from Polka, our payment
+// processor. Deliveries are signed in the Polka-Signature header (see
+// internal/webhook). Every verified delivery is stored before it is
+// processed, and Polka retries until it gets a 2xx, so a redelivered event
+// is acknowledged without being applied twice.
+func (cfg *apiConfig) upgradeUserWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error reading request: %v", err))
+		return
+	}
+	err = webhook.Verify(r.Header.Get("Polka-Signature"), payload, cfg.polkaKeys, time.Now(), polkaWebhookTolerance)
+	if err != nil {
+		cfg.metrics.webhooksReceived.WithLabelValues("polka", "rejected").Inc()
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("Error verifying webhook: %v", err))
+		return
+	}
+	body := polkaEvent{}
+	err = json.Unmarshal(payload, &body)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error decoding request: %v", err))
+		return
+	}
+	if body.ID == "" {
+		respondWithError(w, http.StatusBadRequest, "Webhook event has no id")
+		return
+	}
+
+	_, err = cfg.queries.RecordWebhookEvent(r.Context(), database.RecordWebhookEventParams{
+		Source:    "polka",
+		EventID:   body.ID,
+		EventType: body.Event,
+		Payload:   string(payload),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error recording webhook event: %v", err))
+		return
+	}
+	outcome, err := cfg.processWebhookEvent(r.Context(), "polka", body.ID, false)
+	if outcome == "" {
+		outcome = webhookStatusFailed
+	}
+	cfg.metrics.webhooksReceived.WithLabelValues("polka", outcome).Inc()
+	if errors.Is(err, errWebhookUserNotFound) {
+		respondWithError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error processing webhook event: %v", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type webhookEventResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	Source      string          `json:"source"`
+	EventID     string          `json:"event_id"`
+	EventType   string          `json:"event_type"`
+	Status      string          `json:"status"`
+	Error       string          `json:"error,omitempty"`
+	Attempts    int32           `json:"attempts"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+	Payload     json.RawMessage `json:"payload"`
+}
+
+func newWebhookEventResponse(e database.WebhookEvent) webhookEventResponse {
+	resp := webhookEventResponse{
+		ID:          e.ID,
+		Source:      e.Source,
+		EventID:     e.EventID,
+		EventType:   e.EventType,
+		Status:      e.Status,
+		Error:       e.Error.String,
+		Attempts:    e.Attempts,
+		ReceivedAt:  e.ReceivedAt,
+		ProcessedAt: nullTimePtr(e.ProcessedAt),
+	}
+	// Events recorded before payloads were kept have none.
+	if json.Valid([]byte(e.Payload)) {
+		resp.Payload = json.RawMessage(e.Payload)
+	}
+	return resp
+}
+
+func webhookEventCursor(e database.WebhookEvent) pageCursor {
+	return pageCursor{CreatedAt: e.ReceivedAt, ID: e.ID}
+}
+
+// getWebhookEvents lists stored webhook events, newest first. ?status=
+// narrows it down, e.g. to the failed events worth replaying.
+func (cfg *apiConfig) getWebhookEvents(w http.ResponseWriter, r *http.Request) {
+	type webhookEventsPage struct {
+		Events     []webhookEventResponse `json:"events"`
+		NextCursor string                 `json:"next_cursor,omitempty"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	page, err := parsePageParams(r)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing pagination: %v", err))
+		return
+	}
+	status := sql.NullString{}
+	if s := r.URL.Query().Get("status"); s != "" {
+		if !slices.Contains(webhookStatuses, s) {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Unknown status %q", s))
+			return
+		}
+		status = sql.NullString{String: s, Valid: true}
+	}
+	res, err := cfg.queries.GetWebhookEvents(r.Context(), database.GetWebhookEventsParams{
+		Status:          status,
+		AfterReceivedAt: page.afterCreatedAt(),
+		AfterID:         page.afterID(),
+		PageSize:        page.fetchLimit(),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting webhook events: %v", err))
+		return
+	}
+	events, nextCursor := paginate(res, page, webhookEventCursor)
+	resp := webhookEventsPage{Events: []webhookEventResponse{}, NextCursor: nextCursor}
+	for _, e := range events {
+		resp.Events = append(resp.Events, newWebhookEventResponse(e))
+	}
+	respondWithJSON(w, http.StatusOK, resp)
+}
+
+func (cfg *apiConfig) getWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing event ID: %v", err))
+		return
+	}
+	event, err := cfg.queries.GetWebhookEvent(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting webhook event: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}
+
+// replayWebhookEvent processes a stored event again, even one that already
+// succeeded. The response is the event afterwards; a replay that fails
+// shows up as status "failed" with the error.
+func (cfg *apiConfig) replayWebhookEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	id, err := uuid.Parse(r.PathValue("eventID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("Error parsing event ID: %v", err))
+		return
+	}
+	event, err := cfg.queries.GetWebhookEvent(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Webhook event not found")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting webhook event: %v", err))
+		return
+	}
+	// The outcome is recorded on the event, which is read back below.
+	_, err = cfg.processWebhookEvent(r.Context(), event.Source, event.EventID, true)
+	if err != nil {
+		log.Printf("Error replaying webhook event %s: %v", id, err)
+	}
+	event, err = cfg.queries.GetWebhookEvent(r.Context(), id)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error getting webhook event: %v", err))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, newWebhookEventResponse(event))
+}
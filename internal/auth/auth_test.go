@@ -3,9 +3,6 @@ package auth
 import (
 	"net/http"
 	"testing"
-	"time"
-
-	"github.com/google/uuid"
 )
 
 func TestCheckPasswordHash(t *testing.T) {
@@ -63,54 +60,6 @@ func TestCheckPasswordHash(t *testing.T) {
 	}
 }
 
-func TestValidateJWT(t *testing.T) {
-	userID := uuid.New()
-	validToken, _ := MakeJWT(userID, "secret", time.Hour)
-
-	tests := []struct {
-		name        string
-		tokenString string
-		tokenSecret string
-		wantUserID  uuid.UUID
-		wantErr     bool
-	}{
-		{
-			name:        "Valid token",
-			tokenString: validToken,
-			tokenSecret: "secret",
-			wantUserID:  userID,
-			wantErr:     false,
-		},
-		{
-			name:        "Invalid token",
-			tokenString: "invalid.token.string",
-			tokenSecret: "secret",
-			wantUserID:  uuid.Nil,
-			wantErr:     true,
-		},
-		{
-			name:        "Wrong secret",
-			tokenString: validToken,
-			tokenSecret: "wrong_secret",
-			wantUserID:  uuid.Nil,
-			wantErr:     true,
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			gotUserID, err := ValidateJWT(tt.tokenString, tt.tokenSecret)
-			if (err != nil) != tt.wantErr {
-				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
-				return
-			}
-			if gotUserID != tt.wantUserID {
-				t.Errorf("ValidateJWT() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
-			}
-		})
-	}
-}
-
 func TestGetBearerToken(t *testing.T) {
 	tests := []struct {
 		name      string
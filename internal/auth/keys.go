@@ -0,0 +1,328 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const minRSAKeyBits = 2048
+
+// verificationKey is a public key tokens can be checked against, along with
+// the algorithm it is only ever used with.
+type verificationKey struct {
+	id     string
+	method jwt.SigningMethod
+	public crypto.PublicKey
+}
+
+// Keyring signs access tokens and verifies them against every key that is
+// still active. Asymmetric keys are identified by the kid header, so a new
+// key can start signing while tokens signed by the old one are still in
+// circulation. Without any asymmetric signing key the keyring falls back to
+// HS256 with a shared secret.
+type Keyring struct {
+	signingID  string
+	signingKey crypto.Signer
+	keys       map[string]verificationKey
+	hmacSecret []byte
+	acceptHMAC bool
+}
+
+// NewKeyring returns a keyring that signs and verifies with HS256 using
+// secret. Add asymmetric keys with LoadDir.
+func NewKeyring(secret string) *Keyring {
+	return &Keyring{
+		keys:       map[string]verificationKey{},
+		hmacSecret: []byte(secret),
+		acceptHMAC: true,
+	}
+}
+
+// LoadDir reads every <kid>.pem file in dir. Private keys (PKCS#8, or PKCS#1
+// for RSA) can sign and verify; public keys (PKIX) only verify, which is how
+// a retired key stays valid until the tokens it signed expire. signingID
+// picks the key that signs new tokens; it can be empty when dir holds a
+// single private key.
+//
+// Once an asymmetric key signs, HS256 tokens are rejected unless
+// AcceptHMAC is called, which is meant for the cutover window only.
+func (k *Keyring) LoadDir(dir, signingID string) error {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(paths)
+	signers := map[string]crypto.Signer{}
+	for _, path := range paths {
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		key, signer, err := parseKey(id, data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		k.keys[id] = key
+		if signer != nil {
+			signers[id] = signer
+		}
+	}
+
+	if signingID == "" {
+		if len(signers) != 1 {
+			return fmt.Errorf("%d private keys in %s; choose one to sign with", len(signers), dir)
+		}
+		for id := range signers {
+			signingID = id
+		}
+	}
+	signer, ok := signers[signingID]
+	if !ok {
+		return fmt.Errorf("no private key for signing key ID %q", signingID)
+	}
+	k.signingID = signingID
+	k.signingKey = signer
+	k.acceptHMAC = false
+	return nil
+}
+
+// AcceptHMAC keeps HS256 tokens valid after switching to asymmetric signing.
+func (k *Keyring) AcceptHMAC() {
+	k.acceptHMAC = true
+}
+
+func parseKey(id string, data []byte) (verificationKey, crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return verificationKey{}, nil, errors.New("no PEM data")
+	}
+	var parsed any
+	var err error
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return verificationKey{}, nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return verificationKey{}, nil, err
+	}
+
+	var signer crypto.Signer
+	if s, ok := parsed.(crypto.Signer); ok {
+		signer = s
+		parsed = s.Public()
+	}
+	key := verificationKey{id: id, public: parsed}
+	switch pub := parsed.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < minRSAKeyBits {
+			return verificationKey{}, nil, fmt.Errorf("RSA key is %d bits, need at least %d", pub.N.BitLen(), minRSAKeyBits)
+		}
+		key.method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.method = jwt.SigningMethodEdDSA
+	default:
+		return verificationKey{}, nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+	return key, signer, nil
+}
+
+func (k *Keyring) sign(claims jwt.Claims) (string, error) {
+	if k.signingKey == nil {
+		return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(k.hmacSecret)
+	}
+	token := jwt.NewWithClaims(k.keys[k.signingID].method, claims)
+	token.Header["kid"] = k.signingID
+	return token.SignedString(k.signingKey)
+}
+
+// parse verifies tokenString into claims. Each key only accepts the
+// algorithm it was loaded for, so a token can't pick a weaker one.
+func (k *Keyring) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, hasKID := token.Header["kid"].(string)
+		if !hasKID {
+			if !k.acceptHMAC || token.Method != jwt.SigningMethodHS256 {
+				return nil, errors.New("missing key ID")
+			}
+			return k.hmacSecret, nil
+		}
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key ID %q", kid)
+		}
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+		}
+		return key.public, nil
+	})
+}
+
+// AccessClaims are the claims of an access token. Tokens issued to an
+// OAuth client carry the client's ID and the scopes the user granted it;
+// tokens from a password login carry neither and aren't limited. Only
+// login tokens carry the user's role, so a client never acts with it.
+type AccessClaims struct {
+	jwt.RegisteredClaims
+	Scope    string `json:"scope,omitempty"`
+	ClientID string `json:"client_id,omitempty"`
+	Role     string `json:"role,omitempty"`
+}
+
+func (c AccessClaims) UserID() (uuid.UUID, error) {
+	id, err := uuid.Parse(c.Subject)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid user ID: %w", err)
+	}
+	return id, nil
+}
+
+// Delegated reports whether the token was issued to an OAuth client.
+func (c AccessClaims) Delegated() bool {
+	return c.ClientID != ""
+}
+
+func (c AccessClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
+
+func newClaims(tokenType TokenType, userID uuid.UUID, expiresIn time.Duration) AccessClaims {
+	now := time.Now().UTC()
+	return AccessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    string(tokenType),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			Subject:   userID.String(),
+		},
+	}
+}
+
+func (k *Keyring) parseClaims(tokenString string, tokenType TokenType) (AccessClaims, error) {
+	claims := AccessClaims{}
+	_, err := k.parse(tokenString, &claims)
+	if err != nil {
+		return AccessClaims{}, err
+	}
+	if claims.Issuer != string(tokenType) {
+		return AccessClaims{}, errors.New("invalid issuer")
+	}
+	_, err = claims.UserID()
+	if err != nil {
+		return AccessClaims{}, err
+	}
+	return claims, nil
+}
+
+// MakeJWT signs an access token for userID with the keyring's signing key.
+// The user's role goes in the token as a claim.
+func (k *Keyring) MakeJWT(userID uuid.UUID, role string, expiresIn time.Duration) (string, error) {
+	claims := newClaims(TokenTypeAccess, userID, expiresIn)
+	claims.Role = role
+	return k.sign(claims)
+}
+
+// MakeDelegatedJWT issues an access token to an OAuth client, limited to
+// scopes. tokenID becomes the jti claim, which is how the token is found
+// again for introspection and revocation.
+func (k *Keyring) MakeDelegatedJWT(userID uuid.UUID, clientID string, scopes []string, tokenID uuid.UUID, expiresIn time.Duration) (string, error) {
+	claims := newClaims(TokenTypeAccess, userID, expiresIn)
+	claims.ID = tokenID.String()
+	claims.ClientID = clientID
+	claims.Scope = strings.Join(scopes, " ")
+	return k.sign(claims)
+}
+
+// ParseAccessToken validates any access token, delegated or not, and
+// returns its claims.
+func (k *Keyring) ParseAccessToken(tokenString string) (AccessClaims, error) {
+	return k.parseClaims(tokenString, TokenTypeAccess)
+}
+
+// ValidateJWT checks an access token against every key in the keyring and
+// returns the user it was issued to. It rejects delegated tokens, since
+// their scopes have to be checked.
+func (k *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	claims, err := k.ParseAccessToken(tokenString)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	if claims.Delegated() {
+		return uuid.Nil, errors.New("tokens issued to OAuth clients can't be used here")
+	}
+	return claims.UserID()
+}
+
+// MakeChallengeJWT issues the token a login gets in exchange for a correct
+// password when the user still has to pass two-factor authentication.
+func (k *Keyring) MakeChallengeJWT(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return k.sign(newClaims(TokenTypeTwoFactor, userID, expiresIn))
+}
+
+func (k *Keyring) ValidateChallengeJWT(tokenString string) (uuid.UUID, error) {
+	claims, err := k.parseClaims(tokenString, TokenTypeTwoFactor)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return claims.UserID()
+}
+
+// JWK is a public key in JSON Web Key format (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Ed25519 (RFC 8037)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS returns the public half of every asymmetric key, ordered by key ID.
+// The HS256 secret is never published.
+func (k *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range k.keys {
+		jwk := JWK{KeyID: key.id, Use: "sig", Algorithm: key.method.Alg()}
+		switch pub := key.public.(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].KeyID < set.Keys[j].KeyID })
+	return set
+}
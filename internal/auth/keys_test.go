@@ -0,0 +1,345 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+func writeKey(t *testing.T, dir, id string, key any, public bool) {
+	t.Helper()
+	var block *pem.Block
+	if public {
+		der, err := x509.MarshalPKIXPublicKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PUBLIC KEY", Bytes: der}
+	} else {
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+	}
+	err := os.WriteFile(filepath.Join(dir, id+".pem"), pem.EncodeToMemory(block), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeyringValidateJWT(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	writeKey(t, dir, "old", rsaKey, false)
+	writeKey(t, dir, "new", edKey, false)
+
+	// Sign with the RSA key, then rotate to Ed25519.
+	oldKeys := NewKeyring("secret")
+	if err := oldKeys.LoadDir(dir, "old"); err != nil {
+		t.Fatal(err)
+	}
+	keys := NewKeyring("secret")
+	if err := keys.LoadDir(dir, "new"); err != nil {
+		t.Fatal(err)
+	}
+
+	userID := uuid.New()
+	oldToken, _ := oldKeys.MakeJWT(userID, RoleUser, time.Hour)
+	newToken, _ := keys.MakeJWT(userID, RoleUser, time.Hour)
+	expiredToken, _ := keys.MakeJWT(userID, RoleUser, -time.Minute)
+	hmacToken, _ := NewKeyring("secret").MakeJWT(userID, RoleUser, time.Hour)
+
+	// An HS256 token that claims the RSA key, signed with its public key
+	// bytes as the secret.
+	pubDER, _ := x509.MarshalPKIXPublicKey(&rsaKey.PublicKey)
+	confused := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   userID.String(),
+	})
+	confused.Header["kid"] = "old"
+	confusedToken, _ := confused.SignedString(pubDER)
+
+	unknown := jwt.NewWithClaims(jwt.SigningMethodEdDSA, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   userID.String(),
+	})
+	unknown.Header["kid"] = "missing"
+	unknownToken, _ := unknown.SignedString(edKey)
+
+	tests := []struct {
+		name        string
+		tokenString string
+		wantUserID  uuid.UUID
+		wantErr     bool
+	}{
+		{
+			name:        "Token from current key",
+			tokenString: newToken,
+			wantUserID:  userID,
+			wantErr:     false,
+		},
+		{
+			name:        "Token from rotated-out key",
+			tokenString: oldToken,
+			wantUserID:  userID,
+			wantErr:     false,
+		},
+		{
+			name:        "Expired token",
+			tokenString: expiredToken,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+		{
+			name:        "HS256 token after switching keys",
+			tokenString: hmacToken,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Algorithm confusion",
+			tokenString: confusedToken,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Unknown key ID",
+			tokenString: unknownToken,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, err := keys.ValidateJWT(tt.tokenString)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotUserID != tt.wantUserID {
+				t.Errorf("ValidateJWT() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
+			}
+		})
+	}
+}
+
+func TestKeyringHMAC(t *testing.T) {
+	userID := uuid.New()
+	keys := NewKeyring("secret")
+	validToken, _ := keys.MakeJWT(userID, RoleUser, time.Hour)
+	otherToken, _ := NewKeyring("wrong_secret").MakeJWT(userID, RoleUser, time.Hour)
+
+	// Tokens issued before the keyring had no role claim.
+	legacy := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    string(TokenTypeAccess),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Subject:   userID.String(),
+	})
+	legacyToken, _ := legacy.SignedString([]byte("secret"))
+
+	tests := []struct {
+		name        string
+		tokenString string
+		wantUserID  uuid.UUID
+		wantErr     bool
+	}{
+		{
+			name:        "Valid token",
+			tokenString: validToken,
+			wantUserID:  userID,
+			wantErr:     false,
+		},
+		{
+			name:        "Token without a role claim",
+			tokenString: legacyToken,
+			wantUserID:  userID,
+			wantErr:     false,
+		},
+		{
+			name:        "Invalid token",
+			tokenString: "invalid.token.string",
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+		{
+			name:        "Wrong secret",
+			tokenString: otherToken,
+			wantUserID:  uuid.Nil,
+			wantErr:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotUserID, err := keys.ValidateJWT(tt.tokenString)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateJWT() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if gotUserID != tt.wantUserID {
+				t.Errorf("ValidateJWT() gotUserID = %v, want %v", gotUserID, tt.wantUserID)
+			}
+		})
+	}
+}
+
+func TestKeyringLoadDir(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	weakKey, _ := rsa.GenerateKey(rand.Reader, 1024)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name      string
+		setup     func(dir string)
+		signingID string
+		wantErr   bool
+	}{
+		{
+			name:      "Single private key needs no ID",
+			setup:     func(dir string) { writeKey(t, dir, "a", edKey, false) },
+			signingID: "",
+			wantErr:   false,
+		},
+		{
+			name: "Two private keys need an ID",
+			setup: func(dir string) {
+				writeKey(t, dir, "a", edKey, false)
+				writeKey(t, dir, "b", rsaKey, false)
+			},
+			signingID: "",
+			wantErr:   true,
+		},
+		{
+			name:      "Public key can't sign",
+			setup:     func(dir string) { writeKey(t, dir, "a", edPub, true) },
+			signingID: "a",
+			wantErr:   true,
+		},
+		{
+			name:      "RSA key too small",
+			setup:     func(dir string) { writeKey(t, dir, "a", weakKey, false) },
+			signingID: "a",
+			wantErr:   true,
+		},
+		{
+			name:      "Empty directory",
+			setup:     func(dir string) {},
+			signingID: "",
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			tt.setup(dir)
+			err := NewKeyring("secret").LoadDir(dir, tt.signingID)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("LoadDir() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestKeyringJWKS(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	edPub, edKey, _ := ed25519.GenerateKey(rand.Reader)
+	dir := t.TempDir()
+	writeKey(t, dir, "rsa", rsaKey, false)
+	writeKey(t, dir, "ed", edKey, false)
+	keys := NewKeyring("secret")
+	if err := keys.LoadDir(dir, "ed"); err != nil {
+		t.Fatal(err)
+	}
+
+	set := keys.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("JWKS() has %d keys, want 2", len(set.Keys))
+	}
+	ed, rsaJWK := set.Keys[0], set.Keys[1]
+	if ed.KeyType != "OKP" || ed.Algorithm != "EdDSA" || ed.Curve != "Ed25519" ||
+		ed.X != base64.RawURLEncoding.EncodeToString(edPub) {
+		t.Errorf("Ed25519 JWK = %+v", ed)
+	}
+	if rsaJWK.KeyType != "RSA" || rsaJWK.Algorithm != "RS256" || rsaJWK.E != "AQAB" {
+		t.Errorf("RSA JWK = %+v", rsaJWK)
+	}
+}
+
+func TestKeyringDelegatedJWT(t *testing.T) {
+	userID := uuid.New()
+	tokenID := uuid.New()
+	keys := NewKeyring("secret")
+	token, err := keys.MakeDelegatedJWT(userID, "client-1", []string{ScopeChirpsRead}, tokenID, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := keys.ValidateJWT(token); err == nil {
+		t.Error("ValidateJWT() accepted a delegated token")
+	}
+	claims, err := keys.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if got, _ := claims.UserID(); got != userID {
+		t.Errorf("ParseAccessToken() user = %v, want %v", got, userID)
+	}
+	if !claims.Delegated() || claims.ClientID != "client-1" || claims.ID != tokenID.String() {
+		t.Errorf("ParseAccessToken() claims = %+v", claims)
+	}
+	if scopes := claims.Scopes(); len(scopes) != 1 || scopes[0] != ScopeChirpsRead {
+		t.Errorf("ParseAccessToken() scopes = %v", scopes)
+	}
+
+	// A challenge token isn't an access token, delegated or otherwise.
+	challenge, _ := keys.MakeChallengeJWT(userID, time.Minute)
+	if _, err := keys.ParseAccessToken(challenge); err == nil {
+		t.Error("ParseAccessToken() accepted a challenge token")
+	}
+}
+
+func TestKeyringRoleClaim(t *testing.T) {
+	userID := uuid.New()
+	keys := NewKeyring("secret")
+	token, _ := keys.MakeJWT(userID, RoleModerator, time.Hour)
+	claims, err := keys.ParseAccessToken(token)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if claims.Role != RoleModerator {
+		t.Errorf("ParseAccessToken() role = %q, want %q", claims.Role, RoleModerator)
+	}
+
+	// Delegated tokens never carry a role.
+	delegated, _ := keys.MakeDelegatedJWT(userID, "client-1", []string{ScopeChirpsRead}, uuid.New(), time.Hour)
+	claims, err = keys.ParseAccessToken(delegated)
+	if err != nil {
+		t.Fatalf("ParseAccessToken() error = %v", err)
+	}
+	if claims.Role != "" {
+		t.Errorf("ParseAccessToken() delegated role = %q, want none", claims.Role)
+	}
+}
@@ -0,0 +1,30 @@
+package auth
+
+import "testing"
+
+func TestVerifyPKCE(t *testing.T) {
+	// The example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	tests := []struct {
+		name      string
+		verifier  string
+		challenge string
+		want      bool
+	}{
+		{name: "RFC example", verifier: verifier, challenge: challenge, want: true},
+		{name: "Wrong verifier", verifier: verifier[1:] + "A", challenge: challenge, want: false},
+		{name: "Plain method", verifier: verifier, challenge: verifier, want: false},
+		{name: "Verifier too short", verifier: "abc", challenge: PKCEChallenge("abc"), want: false},
+		{name: "Verifier with invalid characters", verifier: verifier[1:] + "+", challenge: PKCEChallenge(verifier[1:] + "+"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyPKCE(tt.verifier, tt.challenge); got != tt.want {
+				t.Errorf("VerifyPKCE() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,31 @@
+package auth
+
+import "fmt"
+
+// Roles, from least to most privileged. Each role can do everything the
+// ones before it can.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleUser:      1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+}
+
+func ParseRole(role string) (string, error) {
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q", role)
+	}
+	return role, nil
+}
+
+// HasRole reports whether role grants at least the privileges of required.
+// Unknown roles, including none at all, grant nothing.
+func HasRole(role, required string) bool {
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
@@ -0,0 +1,38 @@
+package auth
+
+import "testing"
+
+func TestHasRole(t *testing.T) {
+	tests := []struct {
+		name     string
+		role     string
+		required string
+		want     bool
+	}{
+		{name: "Same role", role: RoleModerator, required: RoleModerator, want: true},
+		{name: "Higher role", role: RoleAdmin, required: RoleModerator, want: true},
+		{name: "Lower role", role: RoleUser, required: RoleAdmin, want: false},
+		{name: "Moderator is not admin", role: RoleModerator, required: RoleAdmin, want: false},
+		{name: "No role", role: "", required: RoleUser, want: false},
+		{name: "Unknown role", role: "root", required: RoleUser, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HasRole(tt.role, tt.required); got != tt.want {
+				t.Errorf("HasRole(%q, %q) = %v, want %v", tt.role, tt.required, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRole(t *testing.T) {
+	for _, role := range []string{RoleUser, RoleModerator, RoleAdmin} {
+		if got, err := ParseRole(role); err != nil || got != role {
+			t.Errorf("ParseRole(%q) = %q, %v", role, got, err)
+		}
+	}
+	if _, err := ParseRole("Admin"); err == nil {
+		t.Errorf("ParseRole(%q) error = nil, want error", "Admin")
+	}
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Scopes limit what a delegated token, such as a personal access token,
+// may do. Tokens from a password login aren't scoped.
+const (
+	ScopeChirpsRead  = "chirps:read"
+	ScopeChirpsWrite = "chirps:write"
+)
+
+var knownScopes = []string{ScopeChirpsRead, ScopeChirpsWrite}
+
+// ParseScopes checks that every scope is known and returns them sorted
+// without duplicates.
+func ParseScopes(scopes []string) ([]string, error) {
+	parsed := make([]string, 0, len(scopes))
+	for _, s := range scopes {
+		s = strings.TrimSpace(s)
+		if !slices.Contains(knownScopes, s) {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+		parsed = append(parsed, s)
+	}
+	slices.Sort(parsed)
+	return slices.Compact(parsed), nil
+}
+
+func HasScope(granted []string, scope string) bool {
+	return slices.Contains(granted, scope)
+}
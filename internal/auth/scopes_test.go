@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseScopes(t *testing.T) {
+	tests := []struct {
+		name       string
+		scopes     []string
+		wantScopes []string
+		wantErr    bool
+	}{
+		{
+			name:       "Known scopes",
+			scopes:     []string{"chirps:write", "chirps:read"},
+			wantScopes: []string{"chirps:read", "chirps:write"},
+			wantErr:    false,
+		},
+		{
+			name:       "Duplicates",
+			scopes:     []string{"chirps:read", " chirps:read"},
+			wantScopes: []string{"chirps:read"},
+			wantErr:    false,
+		},
+		{
+			name:       "No scopes",
+			scopes:     nil,
+			wantScopes: []string{},
+			wantErr:    false,
+		},
+		{
+			name:       "Unknown scope",
+			scopes:     []string{"chirps:read", "admin"},
+			wantScopes: nil,
+			wantErr:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseScopes(tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ParseScopes() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !slices.Equal(got, tt.wantScopes) {
+				t.Errorf("ParseScopes() = %v, want %v", got, tt.wantScopes)
+			}
+		})
+	}
+}
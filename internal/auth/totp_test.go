@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA-1 key from RFC 6238 appendix B,
+// "12345678901234567890", base32 encoded.
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The RFC lists eight-digit codes; six-digit codes are their last six.
+	tests := []struct {
+		name     string
+		unixTime int64
+		wantCode string
+	}{
+		{name: "T=59", unixTime: 59, wantCode: "287082"},
+		{name: "T=1111111109", unixTime: 1111111109, wantCode: "081804"},
+		{name: "T=1234567890", unixTime: 1234567890, wantCode: "005924"},
+		{name: "T=2000000000", unixTime: 2000000000, wantCode: "279037"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unixTime, 0)))
+			if err != nil {
+				t.Fatalf("TOTPCode() error = %v", err)
+			}
+			if got != tt.wantCode {
+				t.Errorf("TOTPCode() = %v, want %v", got, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := TOTPStep(now)
+	code := func(s int64) string {
+		c, _ := TOTPCode(rfc6238Secret, s)
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		lastStep int64
+		wantStep int64
+		wantErr  bool
+	}{
+		{name: "Current code", code: code(step), lastStep: 0, wantStep: step, wantErr: false},
+		{name: "Previous period", code: code(step - 1), lastStep: 0, wantStep: step - 1, wantErr: false},
+		{name: "Next period", code: code(step + 1), lastStep: 0, wantStep: step + 1, wantErr: false},
+		{name: "Outside skew window", code: code(step - 2), lastStep: 0, wantStep: 0, wantErr: true},
+		{name: "Already used", code: code(step), lastStep: step, wantStep: 0, wantErr: true},
+		{name: "Wrong length", code: "12345", lastStep: 0, wantStep: 0, wantErr: true},
+		{name: "Wrong code", code: "000000", lastStep: 0, wantStep: 0, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateTOTP(tt.code, rfc6238Secret, now, tt.lastStep)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTOTP() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if got != tt.wantStep {
+				t.Errorf("ValidateTOTP() = %v, want %v", got, tt.wantStep)
+			}
+		})
+	}
+}
+
+func TestGenerateTOTPSecret(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(secret) != 32 {
+		t.Errorf("GenerateTOTPSecret() = %q, want 32 characters", secret)
+	}
+	if _, err := TOTPCode(secret, 1); err != nil {
+		t.Errorf("TOTPCode() with generated secret error = %v", err)
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri := TOTPURI("Chirpy", "walt@example.com", rfc6238Secret)
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Scheme != "otpauth" || u.Host != "totp" || u.Path != "/Chirpy:walt@example.com" {
+		t.Errorf("TOTPURI() = %v", uri)
+	}
+	if u.Query().Get("secret") != rfc6238Secret || u.Query().Get("issuer") != "Chirpy" {
+		t.Errorf("TOTPURI() query = %v", u.RawQuery)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := GenerateRecoveryCodes(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	for _, c := range codes {
+		if len(c) != 11 || c[5] != '-' {
+			t.Errorf("GenerateRecoveryCodes() code = %q", c)
+		}
+		seen[c] = true
+	}
+	if len(seen) != 10 {
+		t.Errorf("GenerateRecoveryCodes() returned %d distinct codes, want 10", len(seen))
+	}
+	typed := " " + strings.ToUpper(strings.Replace(codes[0], "-", " ", 1)) + " "
+	if NormalizeRecoveryCode(typed) != NormalizeRecoveryCode(codes[0]) {
+		t.Errorf("NormalizeRecoveryCode(%q) = %q", typed, NormalizeRecoveryCode(typed))
+	}
+}
@@ -7,29 +7,86 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-        gen_random_uuid(),
-        now() at time zone 'utc',
-        now() at time zone 'utc',
-        $1,
-        $2
-)
-RETURNING id, created_at, updated_at, body, user_id
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, quote_of)
+SELECT
+    new_chirp.id,
+    now() at time zone 'utc',
+    now() at time zone 'utc',
+    $1::text,
+    $2::uuid,
+    parent.id,
+    COALESCE(parent.conversation_id, new_chirp.id),
+    $3::uuid
+FROM (SELECT gen_random_uuid() AS id) AS new_chirp
+LEFT JOIN chirps AS parent ON parent.id = $4::uuid
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector
 `
 
 type CreateChirpParams struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	QuoteOf   uuid.NullUUID `json:"quote_of"`
+	InReplyTo uuid.NullUUID `json:"in_reply_to"`
 }
 
+// Replies join their parent's conversation; a new top-level chirp starts
+// its own conversation, keyed by its own ID.
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.QuoteOf,
+		arg.InReplyTo,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.Edited,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, conversation_id, rechirp_of)
+SELECT
+    new_chirp.id,
+    now() at time zone 'utc',
+    now() at time zone 'utc',
+    '',
+    $1::uuid,
+    new_chirp.id,
+    $2::uuid
+FROM (SELECT gen_random_uuid() AS id) AS new_chirp
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector
+`
+
+type CreateRechirpParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	RechirpOf uuid.UUID `json:"rechirp_of"`
+}
+
+// A rechirp only points at the original chirp; it has no body of its own.
+// Returns no row if the user has already rechirped that chirp.
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RechirpOf)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +94,13 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.Edited,
+		&i.SearchVector,
 	)
 	return i, err
 }
@@ -66,8 +130,24 @@ func (q *Queries) DeleteChirps(ctx context.Context) error {
 	return err
 }
 
+const deleteRechirp = `-- name: DeleteRechirp :exec
+DELETE FROM chirps
+WHERE user_id = $1
+AND rechirp_of = $2
+`
+
+type DeleteRechirpParams struct {
+	UserID    uuid.UUID     `json:"user_id"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) error {
+	_, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.RechirpOf)
+	return err
+}
+
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
 WHERE id = $1
 `
 
@@ -80,17 +160,101 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.InReplyTo,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.Edited,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getChirpForUpdate = `-- name: GetChirpForUpdate :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
+WHERE id = $1
+FOR UPDATE
+`
+
+func (q *Queries) GetChirpForUpdate(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpForUpdate, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.Edited,
+		&i.SearchVector,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
+WHERE $1::timestamp IS NULL
+   OR (created_at, id) > ($1::timestamp, $2::uuid)
+ORDER BY created_at, id
+LIMIT $3
+`
+
+type GetChirpsParams struct {
+	AfterCreatedAt sql.NullTime  `json:"after_created_at"`
+	AfterID        uuid.NullUUID `json:"after_id"`
+	PageSize       int32         `json:"page_size"`
+}
+
+func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, arg.AfterCreatedAt, arg.AfterID, arg.PageSize)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.InReplyTo,
+			&i.ConversationID,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.Edited,
+			&i.SearchVector,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpsByIDs = `-- name: GetChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
+WHERE id = ANY($1::uuid[])
+ORDER BY created_at, id
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirpsByIDs(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIDs, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -104,6 +268,13 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ConversationID,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.Edited,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -119,13 +290,30 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByUserId = `-- name: GetChirpsByUserId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
 WHERE user_id = $1
-ORDER BY created_at
+AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) > ($2::timestamp, $3::uuid)
+)
+ORDER BY created_at, id
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserId, userID)
+type GetChirpsByUserIdParams struct {
+	UserID         uuid.UUID     `json:"user_id"`
+	AfterCreatedAt sql.NullTime  `json:"after_created_at"`
+	AfterID        uuid.NullUUID `json:"after_id"`
+	PageSize       int32         `json:"page_size"`
+}
+
+func (q *Queries) GetChirpsByUserId(ctx context.Context, arg GetChirpsByUserIdParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserId,
+		arg.UserID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -139,6 +327,13 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ConversationID,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.Edited,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -154,13 +349,30 @@ func (q *Queries) GetChirpsByUserId(ctx context.Context, userID uuid.UUID) ([]Ch
 }
 
 const getChirpsByUserIdDesc = `-- name: GetChirpsByUserIdDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
 WHERE user_id = $1
-ORDER BY created_at DESC
+AND (
+    $2::timestamp IS NULL
+    OR (created_at, id) < ($2::timestamp, $3::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT $4
 `
 
-func (q *Queries) GetChirpsByUserIdDesc(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByUserIdDesc, userID)
+type GetChirpsByUserIdDescParams struct {
+	UserID         uuid.UUID     `json:"user_id"`
+	AfterCreatedAt sql.NullTime  `json:"after_created_at"`
+	AfterID        uuid.NullUUID `json:"after_id"`
+	PageSize       int32         `json:"page_size"`
+}
+
+func (q *Queries) GetChirpsByUserIdDesc(ctx context.Context, arg GetChirpsByUserIdDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByUserIdDesc,
+		arg.UserID,
+		arg.AfterCreatedAt,
+		arg.AfterID,
+		arg.PageSize,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -174,6 +386,13 @@ func (q *Queries) GetChirpsByUserIdDesc(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ConversationID,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.Edited,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -189,12 +408,21 @@ func (q *Queries) GetChirpsByUserIdDesc(ctx context.Context, userID uuid.UUID) (
 }
 
 const getChirpsDesc = `-- name: GetChirpsDesc :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps
-ORDER BY created_at DESC
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
+WHERE $1::timestamp IS NULL
+   OR (created_at, id) < ($1::timestamp, $2::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT $3
 `
 
-func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsDesc)
+type GetChirpsDescParams struct {
+	AfterCreatedAt sql.NullTime  `json:"after_created_at"`
+	AfterID        uuid.NullUUID `json:"after_id"`
+	PageSize       int32         `json:"page_size"`
+}
+
+func (q *Queries) GetChirpsDesc(ctx context.Context, arg GetChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsDesc, arg.AfterCreatedAt, arg.AfterID, arg.PageSize)
 	if err != nil {
 		return nil, err
 	}
@@ -208,6 +436,13 @@ func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.InReplyTo,
+			&i.ConversationID,
+			&i.LikeCount,
+			&i.RechirpOf,
+			&i.QuoteOf,
+			&i.Edited,
+			&i.SearchVector,
 		); err != nil {
 			return nil, err
 		}
@@ -221,3 +456,144 @@ func (q *Queries) GetChirpsDesc(ctx context.Context) ([]Chirp, error) {
 	}
 	return items, nil
 }
+
+const getRechirp = `-- name: GetRechirp :one
+SELECT id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector FROM chirps
+WHERE user_id = $1
+AND rechirp_of = $2
+`
+
+type GetRechirpParams struct {
+	UserID    uuid.UUID     `json:"user_id"`
+	RechirpOf uuid.NullUUID `json:"rechirp_of"`
+}
+
+func (q *Queries) GetRechirp(ctx context.Context, arg GetRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getRechirp, arg.UserID, arg.RechirpOf)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.Edited,
+		&i.SearchVector,
+	)
+	return i, err
+}
+
+const getThreadChirpIDs = `-- name: GetThreadChirpIDs :many
+WITH RECURSIVE ancestors AS (
+    SELECT id, in_reply_to, 0 AS depth
+    FROM chirps
+    WHERE id = $1::uuid
+    UNION ALL
+    SELECT chirps.id, chirps.in_reply_to, ancestors.depth + 1
+    FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.in_reply_to
+    WHERE ancestors.depth < $2::INTEGER
+), replies AS (
+    SELECT id, created_at, 1 AS depth
+    FROM chirps
+    WHERE in_reply_to = $1::uuid
+    UNION ALL
+    SELECT chirps.id, chirps.created_at, replies.depth + 1
+    FROM chirps
+    JOIN replies ON chirps.in_reply_to = replies.id
+    WHERE replies.depth < $2::INTEGER
+)
+SELECT ancestors.id FROM ancestors
+UNION ALL
+(
+    SELECT replies.id FROM replies
+    ORDER BY replies.depth, replies.created_at, replies.id
+    LIMIT $3::INTEGER
+)
+`
+
+type GetThreadChirpIDsParams struct {
+	ChirpID    uuid.UUID `json:"chirp_id"`
+	MaxDepth   int32     `json:"max_depth"`
+	MaxReplies int32     `json:"max_replies"`
+}
+
+// The chirp, its parents up to max_depth levels above it, and its replies
+// up to max_depth levels below it. Replies are taken level by level, oldest
+// first, and stop at max_replies, so a reply's parent is never cut off
+// while the reply is kept.
+func (q *Queries) GetThreadChirpIDs(ctx context.Context, arg GetThreadChirpIDsParams) ([]uuid.UUID, error) {
+	rows, err := q.db.QueryContext(ctx, getThreadChirpIDs, arg.ChirpID, arg.MaxDepth, arg.MaxReplies)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const reparentReplies = `-- name: ReparentReplies :exec
+UPDATE chirps
+SET in_reply_to = $1
+WHERE in_reply_to = $2::uuid
+`
+
+type ReparentRepliesParams struct {
+	NewParentID uuid.NullUUID `json:"new_parent_id"`
+	ChirpID     uuid.UUID     `json:"chirp_id"`
+}
+
+func (q *Queries) ReparentReplies(ctx context.Context, arg ReparentRepliesParams) error {
+	_, err := q.db.ExecContext(ctx, reparentReplies, arg.NewParentID, arg.ChirpID)
+	return err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, edited = TRUE, updated_at = now() at time zone 'utc'
+WHERE id = $2
+RETURNING id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, like_count, rechirp_of, quote_of, edited, search_vector
+`
+
+type UpdateChirpBodyParams struct {
+	Body string    `json:"body"`
+	ID   uuid.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.Body, arg.ID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.InReplyTo,
+		&i.ConversationID,
+		&i.LikeCount,
+		&i.RechirpOf,
+		&i.QuoteOf,
+		&i.Edited,
+		&i.SearchVector,
+	)
+	return i, err
+}
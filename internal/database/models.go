@@ -12,27 +12,192 @@ import (
 )
 
 type Chirp struct {
+	ID             uuid.UUID     `json:"id"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+	Body           string        `json:"body"`
+	UserID         uuid.UUID     `json:"user_id"`
+	InReplyTo      uuid.NullUUID `json:"in_reply_to"`
+	ConversationID uuid.UUID     `json:"conversation_id"`
+	LikeCount      int32         `json:"like_count"`
+	RechirpOf      uuid.NullUUID `json:"rechirp_of"`
+	QuoteOf        uuid.NullUUID `json:"quote_of"`
+	Edited         bool          `json:"edited"`
+	SearchVector   string        `json:"-"`
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Tag       string    `json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChirpLike struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID `json:"chirp_id"`
+	UserID      uuid.UUID `json:"user_id"`
+	StartOffset int32     `json:"start_offset"`
+	EndOffset   int32     `json:"end_offset"`
+}
+
+type ChirpRevision struct {
 	ID        uuid.UUID `json:"id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
 }
 
-type RefreshToken struct {
-	Token     string       `json:"token"`
+type EmailVerificationToken struct {
+	TokenHash string       `json:"token_hash"`
+	UserID    uuid.UUID    `json:"user_id"`
+	Email     string       `json:"email"`
 	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type LoginThrottle struct {
+	Key         string       `json:"key"`
+	Failures    int32        `json:"failures"`
+	LockedUntil sql.NullTime `json:"locked_until"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+type OauthAccessToken struct {
+	ID                    uuid.UUID    `json:"id"`
+	ClientID              string       `json:"client_id"`
+	UserID                uuid.UUID    `json:"user_id"`
+	Scopes                []string     `json:"scopes"`
+	AuthorizationCodeHash string       `json:"authorization_code_hash"`
+	CreatedAt             time.Time    `json:"created_at"`
+	ExpiresAt             time.Time    `json:"expires_at"`
+	RevokedAt             sql.NullTime `json:"revoked_at"`
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string       `json:"code_hash"`
+	ClientID      string       `json:"client_id"`
+	UserID        uuid.UUID    `json:"user_id"`
+	RedirectUri   string       `json:"redirect_uri"`
+	Scopes        []string     `json:"scopes"`
+	CodeChallenge string       `json:"code_challenge"`
+	CreatedAt     time.Time    `json:"created_at"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	UsedAt        sql.NullTime `json:"used_at"`
+}
+
+type OauthClient struct {
+	ID           string         `json:"id"`
+	SecretHash   sql.NullString `json:"secret_hash"`
+	Name         string         `json:"name"`
+	RedirectUris []string       `json:"redirect_uris"`
+	OwnerID      uuid.UUID      `json:"owner_id"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+type PasswordResetToken struct {
+	TokenHash string       `json:"token_hash"`
 	UserID    uuid.UUID    `json:"user_id"`
+	CreatedAt time.Time    `json:"created_at"`
 	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID    `json:"id"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"token_hash"`
+	Hint       string       `json:"hint"`
+	Scopes     []string     `json:"scopes"`
+	CreatedAt  time.Time    `json:"created_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type RecoveryCode struct {
+	UserID    uuid.UUID    `json:"user_id"`
+	CodeHash  string       `json:"code_hash"`
+	CreatedAt time.Time    `json:"created_at"`
+	UsedAt    sql.NullTime `json:"used_at"`
+}
+
+type RefreshToken struct {
+	Token      string         `json:"token"`
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+	UserID     uuid.UUID      `json:"user_id"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	RevokedAt  sql.NullTime   `json:"revoked_at"`
+	FamilyID   uuid.UUID      `json:"family_id"`
+	ReplacedBy sql.NullString `json:"replaced_by"`
+	DeviceName string         `json:"device_name"`
+	UserAgent  string         `json:"user_agent"`
+	Ip         string         `json:"ip"`
+	LastUsedAt time.Time      `json:"last_used_at"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID              uuid.UUID      `json:"id"`
+	CreatedAt       time.Time      `json:"created_at"`
+	UpdatedAt       time.Time      `json:"updated_at"`
+	Email           string         `json:"email"`
+	HashedPassword  string         `json:"hashed_password"`
+	IsChirpyRed     bool           `json:"is_chirpy_red"`
+	Username        sql.NullString `json:"username"`
+	DisplayName     string         `json:"display_name"`
+	Bio             string         `json:"bio"`
+	TotpSecret      sql.NullString `json:"totp_secret"`
+	TotpEnabled     bool           `json:"totp_enabled"`
+	TotpLastStep    int64          `json:"totp_last_step"`
+	EmailVerifiedAt sql.NullTime   `json:"email_verified_at"`
+	PendingEmail    sql.NullString `json:"pending_email"`
+	Role            string         `json:"role"`
+}
+
+type WebhookDelivery struct {
+	ID            uuid.UUID      `json:"id"`
+	EndpointID    uuid.UUID      `json:"endpoint_id"`
+	EventID       uuid.UUID      `json:"event_id"`
+	EventType     string         `json:"event_type"`
+	Payload       string         `json:"payload"`
+	Status        string         `json:"status"`
+	Attempts      int32          `json:"attempts"`
+	NextAttemptAt time.Time      `json:"next_attempt_at"`
+	LastError     sql.NullString `json:"last_error"`
+	CreatedAt     time.Time      `json:"created_at"`
+	DeliveredAt   sql.NullTime   `json:"delivered_at"`
+}
+
+type WebhookEndpoint struct {
+	ID         uuid.UUID `json:"id"`
+	Url        string    `json:"url"`
+	Secret     string    `json:"secret"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type WebhookEvent struct {
+	Source      string         `json:"source"`
+	EventID     string         `json:"event_id"`
+	ReceivedAt  time.Time      `json:"received_at"`
+	ID          uuid.UUID      `json:"id"`
+	EventType   string         `json:"event_type"`
+	Payload     string         `json:"payload"`
+	Status      string         `json:"status"`
+	Error       sql.NullString `json:"error"`
+	Attempts    int32          `json:"attempts"`
+	ProcessedAt sql.NullTime   `json:"processed_at"`
 }
@@ -0,0 +1,185 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"os"
+	"testing"
+)
+
+// These tests run the queries against a database migrated with goose,
+// given in CHIRPY_TEST_DB_URL. CI provides one; locally they are skipped
+// without it.
+
+// testQueries returns Queries inside a transaction that is rolled back when
+// the test ends.
+func testQueries(t *testing.T) *Queries {
+	t.Helper()
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		if os.Getenv("CI") != "" {
+			t.Fatal("CHIRPY_TEST_DB_URL must be set in CI")
+		}
+		t.Skip("CHIRPY_TEST_DB_URL not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("opening database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("starting transaction: %v", err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return New(tx)
+}
+
+func createTestUser(t *testing.T, q *Queries) uuid.UUID {
+	t.Helper()
+	user, err := q.CreateUser(context.Background(), CreateUserParams{
+		Email:          uuid.NewString() + "@example.com",
+		HashedPassword: "unused",
+	})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	return user.ID
+}
+
+func TestCreateChirpReplyAndQuote(t *testing.T) {
+	q := testQueries(t)
+	ctx := context.Background()
+	userID := createTestUser(t, q)
+
+	root, err := q.CreateChirp(ctx, CreateChirpParams{Body: "root", UserID: userID})
+	if err != nil {
+		t.Fatalf("CreateChirp(root): %v", err)
+	}
+	parentID := uuid.NullUUID{UUID: root.ID, Valid: true}
+
+	tests := []struct {
+		name             string
+		params           CreateChirpParams
+		wantInReplyTo    uuid.NullUUID
+		wantQuoteOf      uuid.NullUUID
+		wantConversation func(Chirp) uuid.UUID
+	}{
+		{
+			name:             "reply joins the parent's conversation",
+			params:           CreateChirpParams{Body: "reply", UserID: userID, InReplyTo: parentID},
+			wantInReplyTo:    parentID,
+			wantConversation: func(Chirp) uuid.UUID { return root.ConversationID },
+		},
+		{
+			name:             "quote starts its own conversation",
+			params:           CreateChirpParams{Body: "quote", UserID: userID, QuoteOf: parentID},
+			wantQuoteOf:      parentID,
+			wantConversation: func(c Chirp) uuid.UUID { return c.ID },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chirp, err := q.CreateChirp(ctx, tt.params)
+			if err != nil {
+				t.Fatalf("CreateChirp: %v", err)
+			}
+			if chirp.InReplyTo != tt.wantInReplyTo {
+				t.Errorf("in_reply_to = %v, want %v", chirp.InReplyTo, tt.wantInReplyTo)
+			}
+			if chirp.QuoteOf != tt.wantQuoteOf {
+				t.Errorf("quote_of = %v, want %v", chirp.QuoteOf, tt.wantQuoteOf)
+			}
+			if want := tt.wantConversation(chirp); chirp.ConversationID != want {
+				t.Errorf("conversation_id = %v, want %v", chirp.ConversationID, want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserKeepsOmittedFields(t *testing.T) {
+	q := testQueries(t)
+	ctx := context.Background()
+	email := uuid.NewString() + "@example.com"
+	user, err := q.CreateUser(ctx, CreateUserParams{Email: email, HashedPassword: "old-hash", Bio: "old bio"})
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		params       UpdateUserParams
+		wantPassword string
+		wantBio      string
+	}{
+		{
+			name:         "bio only",
+			params:       UpdateUserParams{Bio: sql.NullString{String: "new bio", Valid: true}},
+			wantPassword: "old-hash",
+			wantBio:      "new bio",
+		},
+		{
+			name:         "password only",
+			params:       UpdateUserParams{HashedPassword: sql.NullString{String: "new-hash", Valid: true}},
+			wantPassword: "new-hash",
+			wantBio:      "new bio",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.params.ID = user.ID
+			updated, err := q.UpdateUser(ctx, tt.params)
+			if err != nil {
+				t.Fatalf("UpdateUser: %v", err)
+			}
+			if updated.Bio != tt.wantBio {
+				t.Errorf("bio = %q, want %q", updated.Bio, tt.wantBio)
+			}
+			if updated.Email != email {
+				t.Errorf("email = %q, want %q", updated.Email, email)
+			}
+			password, err := q.GetUserHashedPasswordByEmail(ctx, email)
+			if err != nil {
+				t.Fatalf("GetUserHashedPasswordByEmail: %v", err)
+			}
+			if password != tt.wantPassword {
+				t.Errorf("hashed_password = %q, want %q", password, tt.wantPassword)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	q := testQueries(t)
+	ctx := context.Background()
+	userID := createTestUser(t, q)
+
+	err := q.CreateRecoveryCodes(ctx, CreateRecoveryCodesParams{UserID: userID, CodeHashes: []string{"hash-a", "hash-b"}})
+	if err != nil {
+		t.Fatalf("CreateRecoveryCodes: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		codeHash string
+		want     int64
+	}{
+		{name: "unused code", codeHash: "hash-a", want: 1},
+		{name: "code already used", codeHash: "hash-a", want: 0},
+		{name: "other code", codeHash: "hash-b", want: 1},
+		{name: "unknown code", codeHash: "hash-c", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used, err := q.UseRecoveryCode(ctx, UseRecoveryCodeParams{UserID: userID, CodeHash: tt.codeHash})
+			if err != nil {
+				t.Fatalf("UseRecoveryCode: %v", err)
+			}
+			if used != tt.want {
+				t.Errorf("UseRecoveryCode(%s) = %d, want %d", tt.codeHash, used, tt.want)
+			}
+		})
+	}
+}
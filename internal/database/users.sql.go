@@ -7,38 +7,70 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countUsersWithRole = `-- name: CountUsersWithRole :one
+SELECT COUNT(*) FROM users
+WHERE role = $1
+`
+
+func (q *Queries) CountUsersWithRole(ctx context.Context, role string) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countUsersWithRole, role)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username, display_name, bio)
 VALUES (
         gen_random_uuid(),
        now() at time zone 'utc',
     now() at time zone 'utc',
         $1,
-        $2
+        $2,
+        $3,
+        $4,
+        $5
 )
-RETURNING id, created_at, updated_at, email, is_chirpy_red
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role
 `
 
 type CreateUserParams struct {
-	Email          string `json:"email"`
-	HashedPassword string `json:"hashed_password"`
+	Email          string         `json:"email"`
+	HashedPassword string         `json:"hashed_password"`
+	Username       sql.NullString `json:"username"`
+	DisplayName    string         `json:"display_name"`
+	Bio            string         `json:"bio"`
 }
 
 type CreateUserRow struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email"`
+	Role          string    `json:"role"`
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, createUser, arg.Email, arg.HashedPassword)
+	row := q.db.QueryRowContext(ctx, createUser,
+		arg.Email,
+		arg.HashedPassword,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+	)
 	var i CreateUserRow
 	err := row.Scan(
 		&i.ID,
@@ -46,6 +78,12 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (CreateU
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.Role,
 	)
 	return i, err
 }
@@ -60,16 +98,22 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role FROM users
 WHERE email = $1
 `
 
 type GetUserByEmailRow struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email"`
+	Role          string    `json:"role"`
 }
 
 func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEmailRow, error) {
@@ -81,6 +125,50 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (GetUserByEm
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.Role,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role FROM users
+WHERE id = $1
+`
+
+type GetUserByIDRow struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email"`
+	Role          string    `json:"role"`
+}
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (GetUserByIDRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i GetUserByIDRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.Role,
 	)
 	return i, err
 }
@@ -97,29 +185,217 @@ func (q *Queries) GetUserHashedPasswordByEmail(ctx context.Context, email string
 	return hashed_password, err
 }
 
-const updateUser = `-- name: UpdateUser :one
+const getUserProfile = `-- name: GetUserProfile :one
+SELECT
+    id,
+    COALESCE(username, '') AS username,
+    display_name,
+    bio,
+    created_at AS joined_at,
+    is_chirpy_red,
+    (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id) AS chirp_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE lower(username) = lower($1)
+`
+
+type GetUserProfileRow struct {
+	ID             uuid.UUID `json:"id"`
+	Username       string    `json:"username"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	JoinedAt       time.Time `json:"joined_at"`
+	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ChirpCount     int64     `json:"chirp_count"`
+	FollowerCount  int64     `json:"follower_count"`
+	FollowingCount int64     `json:"following_count"`
+}
+
+// Public view of a user. Never select email or hashed_password here.
+func (q *Queries) GetUserProfile(ctx context.Context, username string) (GetUserProfileRow, error) {
+	row := q.db.QueryRowContext(ctx, getUserProfile, username)
+	var i GetUserProfileRow
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.JoinedAt,
+		&i.IsChirpyRed,
+		&i.ChirpCount,
+		&i.FollowerCount,
+		&i.FollowingCount,
+	)
+	return i, err
+}
+
+const getUsersByUsernames = `-- name: GetUsersByUsernames :many
+SELECT id, COALESCE(username, '') AS username FROM users
+WHERE lower(username) = ANY($1::text[])
+`
+
+type GetUsersByUsernamesRow struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+}
+
+func (q *Queries) GetUsersByUsernames(ctx context.Context, usernames []string) ([]GetUsersByUsernamesRow, error) {
+	rows, err := q.db.QueryContext(ctx, getUsersByUsernames, pq.Array(usernames))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetUsersByUsernamesRow
+	for rows.Next() {
+		var i GetUsersByUsernamesRow
+		if err := rows.Scan(&i.ID, &i.Username); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const isEmailVerified = `-- name: IsEmailVerified :one
+SELECT (email_verified_at IS NOT NULL)::boolean AS email_verified FROM users
+WHERE id = $1
+`
+
+func (q *Queries) IsEmailVerified(ctx context.Context, id uuid.UUID) (bool, error) {
+	row := q.db.QueryRowContext(ctx, isEmailVerified, id)
+	var email_verified bool
+	err := row.Scan(&email_verified)
+	return email_verified, err
+}
+
+const setPendingEmail = `-- name: SetPendingEmail :exec
 UPDATE users
-SET email = $1, updated_at = now() at time zone 'utc', hashed_password = $2
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, is_chirpy_red
+SET pending_email = $1, updated_at = now() at time zone 'utc'
+WHERE id = $2
 `
 
-type UpdateUserParams struct {
-	Email          string    `json:"email"`
+type SetPendingEmailParams struct {
+	PendingEmail sql.NullString `json:"pending_email"`
+	ID           uuid.UUID      `json:"id"`
+}
+
+// The current address stays in use until the new one is verified.
+func (q *Queries) SetPendingEmail(ctx context.Context, arg SetPendingEmailParams) error {
+	_, err := q.db.ExecContext(ctx, setPendingEmail, arg.PendingEmail, arg.ID)
+	return err
+}
+
+const setUserPassword = `-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = now() at time zone 'utc'
+WHERE id = $2
+`
+
+type SetUserPasswordParams struct {
 	HashedPassword string    `json:"hashed_password"`
 	ID             uuid.UUID `json:"id"`
 }
 
+func (q *Queries) SetUserPassword(ctx context.Context, arg SetUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, setUserPassword, arg.HashedPassword, arg.ID)
+	return err
+}
+
+const setUserRole = `-- name: SetUserRole :one
+UPDATE users
+SET role = $1,
+    updated_at = now() at time zone 'utc'
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role
+`
+
+type SetUserRoleParams struct {
+	Role string    `json:"role"`
+	ID   uuid.UUID `json:"id"`
+}
+
+type SetUserRoleRow struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email"`
+	Role          string    `json:"role"`
+}
+
+func (q *Queries) SetUserRole(ctx context.Context, arg SetUserRoleParams) (SetUserRoleRow, error) {
+	row := q.db.QueryRowContext(ctx, setUserRole, arg.Role, arg.ID)
+	var i SetUserRoleRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.Role,
+	)
+	return i, err
+}
+
+const updateUser = `-- name: UpdateUser :one
+UPDATE users
+SET
+    updated_at = now() at time zone 'utc',
+    hashed_password = COALESCE($1, hashed_password),
+    username = COALESCE($2, username),
+    display_name = COALESCE($3, display_name),
+    bio = COALESCE($4, bio)
+WHERE id = $5
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role
+`
+
+type UpdateUserParams struct {
+	HashedPassword sql.NullString `json:"hashed_password"`
+	Username       sql.NullString `json:"username"`
+	DisplayName    sql.NullString `json:"display_name"`
+	Bio            sql.NullString `json:"bio"`
+	ID             uuid.UUID      `json:"id"`
+}
+
 type UpdateUserRow struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email"`
+	Role          string    `json:"role"`
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateUserRow, error) {
-	row := q.db.QueryRowContext(ctx, updateUser, arg.Email, arg.HashedPassword, arg.ID)
+	row := q.db.QueryRowContext(ctx, updateUser,
+		arg.HashedPassword,
+		arg.Username,
+		arg.DisplayName,
+		arg.Bio,
+		arg.ID,
+	)
 	var i UpdateUserRow
 	err := row.Scan(
 		&i.ID,
@@ -127,6 +403,12 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (UpdateU
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.Role,
 	)
 	return i, err
 }
@@ -135,7 +417,7 @@ const upgradeUser = `-- name: UpgradeUser :one
 UPDATE users
 SET is_chirpy_red = $1
 WHERE id = $2
-RETURNING id, created_at, updated_at, email, is_chirpy_red
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role
 `
 
 type UpgradeUserParams struct {
@@ -144,11 +426,17 @@ type UpgradeUserParams struct {
 }
 
 type UpgradeUserRow struct {
-	ID          uuid.UUID `json:"id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	Email       string    `json:"email"`
-	IsChirpyRed bool      `json:"is_chirpy_red"`
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email"`
+	Role          string    `json:"role"`
 }
 
 func (q *Queries) UpgradeUser(ctx context.Context, arg UpgradeUserParams) (UpgradeUserRow, error) {
@@ -160,6 +448,65 @@ func (q *Queries) UpgradeUser(ctx context.Context, arg UpgradeUserParams) (Upgra
 		&i.UpdatedAt,
 		&i.Email,
 		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.Role,
+	)
+	return i, err
+}
+
+const verifyUserEmail = `-- name: VerifyUserEmail :one
+UPDATE users
+SET
+    email = $1,
+    email_verified_at = now() at time zone 'utc',
+    pending_email = CASE WHEN pending_email = $1 THEN NULL ELSE pending_email END,
+    updated_at = now() at time zone 'utc'
+WHERE id = $2
+AND (email = $1 OR pending_email = $1)
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role
+`
+
+type VerifyUserEmailParams struct {
+	Email string    `json:"email"`
+	ID    uuid.UUID `json:"id"`
+}
+
+type VerifyUserEmailRow struct {
+	ID            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	Username      string    `json:"username"`
+	DisplayName   string    `json:"display_name"`
+	Bio           string    `json:"bio"`
+	EmailVerified bool      `json:"email_verified"`
+	PendingEmail  string    `json:"pending_email"`
+	Role          string    `json:"role"`
+}
+
+// Marks email as verified, switching to it if it was pending. Returns no
+// rows if email is neither the current nor the pending address, which
+// happens when the user has since asked for a different change.
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (VerifyUserEmailRow, error) {
+	row := q.db.QueryRowContext(ctx, verifyUserEmail, arg.Email, arg.ID)
+	var i VerifyUserEmailRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.IsChirpyRed,
+		&i.Username,
+		&i.DisplayName,
+		&i.Bio,
+		&i.EmailVerified,
+		&i.PendingEmail,
+		&i.Role,
 	)
 	return i, err
 }
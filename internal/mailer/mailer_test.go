@@ -0,0 +1,69 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFormat(t *testing.T) {
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		name     string
+		msg      Message
+		wantText []string
+		wantErr  bool
+	}{
+		{
+			name: "Plain message",
+			msg:  Message{To: "walt@example.com", Subject: "Hello", Body: "line one\nline two"},
+			wantText: []string{
+				"From: chirpy@example.com\r\n",
+				"To: walt@example.com\r\n",
+				"Subject: Hello\r\n",
+				"Date: Tue, 02 Jan 2024 03:04:05 +0000\r\n",
+				"\r\n\r\nline one\r\nline two",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "Header injection in subject",
+			msg:     Message{To: "walt@example.com", Subject: "Hi\r\nBcc: everyone@example.com"},
+			wantErr: true,
+		},
+		{
+			name:    "Header injection in recipient",
+			msg:     Message{To: "walt@example.com\nBcc: everyone@example.com", Subject: "Hi"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := format("chirpy@example.com", tt.msg, now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("format() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			for _, want := range tt.wantText {
+				if !strings.Contains(string(got), want) {
+					t.Errorf("format() = %q, missing %q", got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLogMailer(t *testing.T) {
+	var buf bytes.Buffer
+	m := NewLogMailer(&buf, "chirpy@example.com")
+	err := m.Send(context.Background(), Message{To: "walt@example.com", Subject: "Reset", Body: "token: abc"})
+	if err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !strings.Contains(buf.String(), "To: walt@example.com") || !strings.Contains(buf.String(), "token: abc") {
+		t.Errorf("Send() wrote %q", buf.String())
+	}
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Event types Chirpy sends to subscribed endpoints.
+const (
+	EventChirpCreated   = "chirp.created"
+	EventChirpDeleted   = "chirp.deleted"
+	EventUserCreated    = "user.created"
+	EventUserUpgraded   = "user.upgraded"
+	EventUserDowngraded = "user.downgraded"
+)
+
+var EventTypes = []string{EventChirpCreated, EventChirpDeleted, EventUserCreated, EventUserUpgraded, EventUserDowngraded}
+
+// Headers sent with every delivery. Receivers should check the signature
+// with Verify and use the event ID to drop duplicates, since a delivery can
+// arrive more than once.
+const (
+	HeaderSignature = "Chirpy-Signature"
+	HeaderEventID   = "Chirpy-Event-Id"
+	HeaderEventType = "Chirpy-Event-Type"
+)
+
+// Retry schedule: the wait doubles after each failed attempt, starting at
+// baseBackoff and capped at maxBackoff. After MaxAttempts the delivery is
+// dead-lettered, about 15 hours after the event.
+const (
+	MaxAttempts = 12
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// Backoff returns how long to wait after the given number of failed
+// attempts, and false once no attempts are left.
+func Backoff(attempts int) (time.Duration, bool) {
+	if attempts >= MaxAttempts {
+		return 0, false
+	}
+	d := baseBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff), true
+}
+
+// Delivery is one event on its way to one endpoint.
+type Delivery struct {
+	URL       string
+	Secret    string
+	EventID   string
+	EventType string
+	Payload   []byte
+}
+
+// StatusError is a delivery the endpoint answered with something other
+// than 2xx.
+type StatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("endpoint responded %d", e.StatusCode)
+	}
+	return fmt.Sprintf("endpoint responded %d: %s", e.StatusCode, e.Body)
+}
+
+// Sender POSTs deliveries to endpoints.
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender returns a sender that gives each attempt timeout to finish.
+// Redirects aren't followed: a signed payload should only go to the URL
+// that was registered.
+func NewSender(timeout time.Duration) *Sender {
+	return &Sender{client: &http.Client{
+		Timeout: timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}}
+}
+
+// Send makes one attempt at d, signed at now. Any response but a 2xx is
+// an error.
+func (s *Sender) Send(ctx context.Context, d Delivery, now time.Time) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(HeaderEventID, d.EventID)
+	req.Header.Set(HeaderEventType, d.EventType)
+	req.Header.Set(HeaderSignature, Sign(d.Payload, now, d.Secret))
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(bytes.TrimSpace(body))}
+	}
+	// Drain a little so the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	return nil
+}
@@ -0,0 +1,110 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSend(t *testing.T) {
+	payload := []byte(`{"id":"3b0e7a52-6d1a-4d53-9a4e-5d5f3c1f0e11","type":"chirp.created"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		handler    http.HandlerFunc
+		wantStatus int // of the StatusError, or 0 for success
+	}{
+		{
+			name: "Receiver accepts",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				err := Verify(r.Header.Get(HeaderSignature), body, []string{"secret"}, time.Now(), time.Minute)
+				if err != nil || r.Header.Get(HeaderEventType) != EventChirpCreated || r.Header.Get(HeaderEventID) != "evt-1" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			},
+			wantStatus: 0,
+		},
+		{
+			name: "Receiver fails",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "try later", http.StatusServiceUnavailable)
+			},
+			wantStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name: "Redirects aren't followed",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/moved" {
+					w.WriteHeader(http.StatusOK)
+					return
+				}
+				http.Redirect(w, r, "/moved", http.StatusTemporaryRedirect)
+			},
+			wantStatus: http.StatusTemporaryRedirect,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(tt.handler)
+			defer srv.Close()
+			err := NewSender(5*time.Second).Send(context.Background(), Delivery{
+				URL:       srv.URL,
+				Secret:    "secret",
+				EventID:   "evt-1",
+				EventType: EventChirpCreated,
+				Payload:   payload,
+			}, now)
+			if tt.wantStatus == 0 {
+				if err != nil {
+					t.Errorf("Send() error = %v", err)
+				}
+				return
+			}
+			var statusErr *StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.wantStatus {
+				t.Errorf("Send() error = %v, want status %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer srv.Close()
+	err := NewSender(50*time.Millisecond).Send(context.Background(), Delivery{URL: srv.URL}, time.Now())
+	if err == nil {
+		t.Error("Send() error = nil, want timeout")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+		wantOK   bool
+	}{
+		{attempts: 1, want: 30 * time.Second, wantOK: true},
+		{attempts: 2, want: time.Minute, wantOK: true},
+		{attempts: 5, want: 8 * time.Minute, wantOK: true},
+		{attempts: 11, want: 6 * time.Hour, wantOK: true},
+		{attempts: MaxAttempts, want: 0, wantOK: false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Backoff(tt.attempts)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("Backoff(%d) = %v, %v, want %v, %v", tt.attempts, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
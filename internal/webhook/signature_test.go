@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"evt_1","event":"user.upgraded"}`)
+	now := time.Unix(1700000000, 0)
+	tolerance := 5 * time.Minute
+
+	tests := []struct {
+		name    string
+		header  string
+		body    []byte
+		secrets []string
+		wantErr error
+	}{
+		{
+			name:    "Valid signature",
+			header:  Sign(body, now, "current"),
+			body:    body,
+			secrets: []string{"current", ""},
+			wantErr: nil,
+		},
+		{
+			name:    "Signed with the previous secret",
+			header:  Sign(body, now, "previous"),
+			body:    body,
+			secrets: []string{"current", "previous"},
+			wantErr: nil,
+		},
+		{
+			name:    "Sender signs with both secrets",
+			header:  Sign(body, now, "old", "current"),
+			body:    body,
+			secrets: []string{"current"},
+			wantErr: nil,
+		},
+		{
+			name:    "Wrong secret",
+			header:  Sign(body, now, "other"),
+			body:    body,
+			secrets: []string{"current"},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Tampered body",
+			header:  Sign(body, now, "current"),
+			body:    []byte(`{"id":"evt_1","event":"user.downgraded"}`),
+			secrets: []string{"current"},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Too old",
+			header:  Sign(body, now.Add(-tolerance-time.Second), "current"),
+			body:    body,
+			secrets: []string{"current"},
+			wantErr: ErrTimestamp,
+		},
+		{
+			name:    "Too far in the future",
+			header:  Sign(body, now.Add(tolerance+time.Second), "current"),
+			body:    body,
+			secrets: []string{"current"},
+			wantErr: ErrTimestamp,
+		},
+		{
+			name:    "No signatures",
+			header:  "t=1700000000",
+			body:    body,
+			secrets: []string{"current"},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Empty secret never matches",
+			header:  Sign(body, now, ""),
+			body:    body,
+			secrets: []string{""},
+			wantErr: ErrInvalidSignature,
+		},
+		{
+			name:    "Malformed header",
+			header:  "ApiKey f271c81ff7084ee5b99a5091b42d486e",
+			body:    body,
+			secrets: []string{"current"},
+			wantErr: ErrInvalidSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify(tt.header, tt.body, tt.secrets, now, tolerance)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Verify() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"context"
+	"github.com/google/uuid"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// throttlePolicy slows down password guessing. After freeFailures failed
+// attempts each further failure doubles the wait before the next attempt,
+// starting at baseDelay. At lockoutFailures the key is locked for
+// maxDelay. Failures are forgotten after resetAfter without a new one.
+type throttlePolicy struct {
+	freeFailures    int32
+	lockoutFailures int32
+	baseDelay       time.Duration
+	maxDelay        time.Duration
+	resetAfter      time.Duration
+}
+
+// Per-account limits protect one user from a distributed attack; per-IP
+// limits stop one client spraying guesses across many accounts. IPs get
+// more room since many people can share one.
+var (
+	accountThrottle = throttlePolicy{
+		freeFailures:    3,
+		lockoutFailures: 10,
+		baseDelay:       time.Second,
+		maxDelay:        15 * time.Minute,
+		resetAfter:      time.Hour,
+	}
+	ipThrottle = throttlePolicy{
+		freeFailures:    20,
+		lockoutFailures: 100,
+		baseDelay:       time.Second,
+		maxDelay:        15 * time.Minute,
+		resetAfter:      time.Hour,
+	}
+	// Reset requests send email, so they're limited however they turn out:
+	// nobody needs more than a few links an hour.
+	resetEmailThrottle = throttlePolicy{
+		freeFailures:    3,
+		lockoutFailures: 5,
+		baseDelay:       time.Minute,
+		maxDelay:        time.Hour,
+		resetAfter:      time.Hour,
+	}
+	resetIPThrottle = throttlePolicy{
+		freeFailures:    10,
+		lockoutFailures: 30,
+		baseDelay:       time.Minute,
+		maxDelay:        time.Hour,
+		resetAfter:      time.Hour,
+	}
+)
+
+func (p throttlePolicy) delay(failures int32) time.Duration {
+	if failures >= p.lockoutFailures {
+		return p.maxDelay
+	}
+	if failures <= p.freeFailures {
+		return 0
+	}
+	d := float64(p.baseDelay) * math.Pow(2, float64(failures-p.freeFailures-1))
+	return time.Duration(min(d, float64(p.maxDelay)))
+}
+
+// loginThrottle is the set of throttle keys an attempt counts against.
+type loginThrottle struct {
+	keys     []string
+	policies []throttlePolicy
+}
+
+func (cfg *apiConfig) passwordLoginThrottle(r *http.Request, email string) loginThrottle {
+	return loginThrottle{
+		keys:     []string{"email:" + strings.ToLower(email), "ip:" + cfg.clientIP(r)},
+		policies: []throttlePolicy{accountThrottle, ipThrottle},
+	}
+}
+
+// twoFactorLoginThrottle limits guesses at the second factor, which has far
+// fewer possible values than a password.
+func (cfg *apiConfig) twoFactorLoginThrottle(r *http.Request, userID uuid.UUID) loginThrottle {
+	return loginThrottle{
+		keys:     []string{"2fa:" + userID.String(), "ip:" + cfg.clientIP(r)},
+		policies: []throttlePolicy{accountThrottle, ipThrottle},
+	}
+}
+
+// passwordResetThrottle limits reset emails to one address and from one
+// client. Every request counts, since there's no failure to tell apart.
+func (cfg *apiConfig) passwordResetThrottle(r *http.Request, email string) loginThrottle {
+	return loginThrottle{
+		keys:     []string{"reset-email:" + strings.ToLower(email), "reset-ip:" + cfg.clientIP(r)},
+		policies: []throttlePolicy{resetEmailThrottle, resetIPThrottle},
+	}
+}
+
+// beginLoginAttempt counts an attempt against every key in t before the
+// credentials are checked, so a burst of concurrent guesses can't all get
+// past the limit on the same count. Each key's row stays locked until the
+// count and any resulting lockout are committed. If a key is already locked
+// nothing is counted and the wait is returned.
+func (cfg *apiConfig) beginLoginAttempt(ctx context.Context, t loginThrottle) (time.Duration, error) {
+	tx, err := cfg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.queries.WithTx(tx)
+
+	var retryAfter time.Duration
+	for i, key := range t.keys {
+		policy := t.policies[i]
+		attempt, err := qtx.RecordLoginAttempt(ctx, database.RecordLoginAttemptParams{
+			Key:               key,
+			ResetAfterSeconds: int32(policy.resetAfter / time.Second),
+		})
+		if err != nil {
+			return 0, err
+		}
+		if attempt.RetryAfter > 0 {
+			retryAfter = max(retryAfter, time.Duration(attempt.RetryAfter)*time.Second)
+			continue
+		}
+		delay := policy.delay(attempt.Failures)
+		if delay == 0 {
+			continue
+		}
+		err = qtx.LockLogin(ctx, database.LockLoginParams{
+			LockSeconds: int32(math.Ceil(delay.Seconds())),
+			Key:         key,
+		})
+		if err != nil {
+			return 0, err
+		}
+	}
+	if retryAfter > 0 {
+		return retryAfter, nil
+	}
+	return 0, tx.Commit()
+}
+
+// recordLoginSuccess clears the account's failures. The IP only gets this
+// attempt back; the rest of its failures are left to expire, or anyone
+// could reset their IP's count by logging into an account of their own
+// between guesses.
+func (cfg *apiConfig) recordLoginSuccess(ctx context.Context, t loginThrottle) error {
+	err := cfg.queries.ClearLoginFailures(ctx, t.keys[0])
+	if err != nil {
+		return err
+	}
+	return cfg.queries.ReleaseLoginAttempt(ctx, t.keys[1:])
+}
+
+func respondTooManyAttempts(w http.ResponseWriter, retryAfter time.Duration) {
+	respondTooManyRequests(w, retryAfter, "Too many failed login attempts; try again later")
+}
+
+func respondTooManyRequests(w http.ResponseWriter, retryAfter time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, msg)
+}
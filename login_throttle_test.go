@@ -0,0 +1,45 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottlePolicyDelay(t *testing.T) {
+	policy := throttlePolicy{
+		freeFailures:    3,
+		lockoutFailures: 10,
+		baseDelay:       time.Second,
+		maxDelay:        time.Minute,
+		resetAfter:      time.Hour,
+	}
+
+	tests := []struct {
+		name     string
+		failures int32
+		want     time.Duration
+	}{
+		{name: "No failures", failures: 0, want: 0},
+		{name: "Last free failure", failures: 3, want: 0},
+		{name: "First delayed failure", failures: 4, want: time.Second},
+		{name: "Delay doubles", failures: 5, want: 2 * time.Second},
+		{name: "Delay doubles again", failures: 7, want: 8 * time.Second},
+		{name: "Last failure before lockout", failures: 9, want: 32 * time.Second},
+		{name: "Lockout", failures: 10, want: time.Minute},
+		{name: "Past lockout", failures: 50, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.delay(tt.failures); got != tt.want {
+				t.Errorf("delay(%d) = %v, want %v", tt.failures, got, tt.want)
+			}
+		})
+	}
+
+	capped := policy
+	capped.maxDelay = 5 * time.Second
+	if got := capped.delay(9); got != 5*time.Second {
+		t.Errorf("delay(9) with maxDelay 5s = %v, want 5s", got)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"chirpy/internal/auth"
+	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
+	"fmt"
+	"github.com/google/uuid"
+	"log"
+	"net/url"
+)
+
+// sendMailAsync sends msg in the background. Callers respond without
+// waiting, so slow mail servers don't hold up requests and response times
+// don't reveal whether an email went out.
+func (cfg *apiConfig) sendMailAsync(msg mailer.Message) {
+	go func() {
+		err := cfg.mailer.Send(context.Background(), msg)
+		if err != nil {
+			log.Printf("Error sending %q email: %v", msg.Subject, err)
+		}
+	}()
+}
+
+// createEmailVerification stores a verification token for email and
+// returns the message carrying it. q may be a transaction; send the message
+// once it commits.
+func (cfg *apiConfig) createEmailVerification(ctx context.Context, q *database.Queries, userID uuid.UUID, email string) (mailer.Message, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	err = q.CreateEmailVerificationToken(ctx, database.CreateEmailVerificationTokenParams{
+		TokenHash: auth.HashToken(token),
+		UserID:    userID,
+		Email:     email,
+	})
+	if err != nil {
+		return mailer.Message{}, err
+	}
+	return mailer.Message{
+		To:      email,
+		Subject: "Verify your Chirpy email address",
+		Body: fmt.Sprintf(
+			"Please confirm this is your email address by opening this link within the next 24 hours:\n\n"+
+				"%s/api/verify-email?token=%s\n\n"+
+				"If you didn't sign up for Chirpy or change your email, you can ignore this email.\n",
+			cfg.appURL, url.QueryEscape(token),
+		),
+	}, nil
+}
@@ -1,13 +1,15 @@
 package main
 
 import (
+	"chirpy/internal/auth"
 	"chirpy/internal/database"
+	"chirpy/internal/mailer"
+	"context"
 	"database/sql"
 	"github.com/joho/godotenv"
 	"log"
 	"net/http"
 	"os"
-	"sync/atomic"
 )
 import _ "github.com/lib/pq"
 
@@ -25,12 +27,54 @@ func main() {
 		log.Fatalf("Error opening database: %v", err)
 	}
 
+	if len(os.Args) > 1 {
+		err = runCommand(context.Background(), db, os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	keys := auth.NewKeyring(os.Getenv("SECRET_TOKEN"))
+	if keysDir := os.Getenv("JWT_KEYS_DIR"); keysDir != "" {
+		err = keys.LoadDir(keysDir, os.Getenv("JWT_SIGNING_KID"))
+		if err != nil {
+			log.Fatalf("Error loading JWT keys: %v", err)
+		}
+		if os.Getenv("JWT_ACCEPT_HS256") == "true" {
+			keys.AcceptHMAC()
+		}
+	}
+
+	schemaVersion, err := latestSchemaVersion(schemaFiles)
+	if err != nil {
+		log.Fatalf("Error reading embedded migrations: %v", err)
+	}
+
+	proxyHops, err := parseProxyHops(os.Getenv("TRUST_PROXY_HEADERS"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mail, err := newMailer()
+	if err != nil {
+		log.Fatalf("Error configuring mailer: %v", err)
+	}
+
 	cfg := apiConfig{
-		fileserverHits: atomic.Int32{},
-		queries:        database.New(db),
-		platform:       os.Getenv("PLATFORM"),
-		secretToken:    os.Getenv("SECRET_TOKEN"),
-		polkaKey:       os.Getenv("POLKA_KEY"),
+		db:                   db,
+		queries:              database.New(db),
+		platform:             os.Getenv("PLATFORM"),
+		secretToken:          os.Getenv("SECRET_TOKEN"),
+		keys:                 keys,
+		polkaKeys:            []string{os.Getenv("POLKA_KEY"), os.Getenv("POLKA_KEY_PREVIOUS")},
+		proxyHops:            proxyHops,
+		mailer:               mail,
+		appURL:               os.Getenv("APP_URL"),
+		requireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
+		metrics:              newServerMetrics(db),
+		metricsToken:         os.Getenv("METRICS_TOKEN"),
+		schemaVersion:        schemaVersion,
 	}
 
 	mux := http.NewServeMux()
@@ -38,29 +82,110 @@ func main() {
 		"/app/",
 		cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot)))),
 	)
-	mux.HandleFunc("GET /api/healthz", handlerReadiness)
+	mux.HandleFunc("GET /api/healthz", handlerHealthz)
+	mux.HandleFunc("GET /api/livez", handlerLiveness)
+	mux.HandleFunc("GET /api/readyz", cfg.handlerReadiness)
+	mux.HandleFunc("GET /metrics", cfg.handlerPrometheus)
+	mux.HandleFunc("GET /.well-known/jwks.json", cfg.handlerJWKS)
 
-	mux.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
-	mux.HandleFunc("POST /admin/reset", cfg.handlerReset)
+	// Everything under /admin/ goes through the admin mux, so new admin
+	// routes can't be added without the role check.
+	admin := http.NewServeMux()
+	admin.HandleFunc("GET /admin/metrics", cfg.handlerMetrics)
+	admin.HandleFunc("POST /admin/reset", cfg.handlerReset)
+	admin.HandleFunc("PUT /admin/users/{userID}/role", cfg.setUserRole)
+	admin.HandleFunc("GET /admin/webhook-events", cfg.getWebhookEvents)
+	admin.HandleFunc("GET /admin/webhook-events/{eventID}", cfg.getWebhookEvent)
+	admin.HandleFunc("POST /admin/webhook-events/{eventID}/replay", cfg.replayWebhookEvent)
+	admin.HandleFunc("POST /admin/webhook-endpoints", cfg.createWebhookEndpoint)
+	admin.HandleFunc("GET /admin/webhook-endpoints", cfg.getWebhookEndpoints)
+	admin.HandleFunc("DELETE /admin/webhook-endpoints/{endpointID}", cfg.deleteWebhookEndpoint)
+	admin.HandleFunc("GET /admin/webhook-endpoints/{endpointID}/deliveries", cfg.getWebhookDeliveries)
+	admin.HandleFunc("POST /admin/webhook-deliveries/{deliveryID}/retry", cfg.retryWebhookDelivery)
+	mux.Handle("/admin/", cfg.requireRole(auth.RoleAdmin, admin))
 
 	mux.HandleFunc("POST /api/chirps", cfg.createChirp)
 	mux.HandleFunc("GET /api/chirps", cfg.getChirps)
+	mux.HandleFunc("GET /api/chirps/search", cfg.searchChirps)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.getChirp)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.updateChirp)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", cfg.getChirpRevisions)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", cfg.getChirpThread)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", cfg.likeChirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", cfg.unlikeChirp)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", cfg.createRechirp)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", cfg.deleteRechirp)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", cfg.deleteChirp)
 
+	mux.HandleFunc("GET /api/hashtags/trending", cfg.getTrendingHashtags)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", cfg.getHashtagChirps)
+
 	mux.HandleFunc("POST /api/login", cfg.login)
+	mux.HandleFunc("POST /api/login/2fa", cfg.loginTwoFactor)
 	mux.HandleFunc("POST /api/refresh", cfg.refreshLoginToken)
 	mux.HandleFunc("POST /api/revoke", cfg.revokeLoginToken)
+	mux.HandleFunc("POST /api/password-reset/request", cfg.requestPasswordReset)
+	mux.HandleFunc("POST /api/password-reset/confirm", cfg.confirmPasswordReset)
+	mux.HandleFunc("GET /api/verify-email", cfg.verifyEmail)
+	mux.HandleFunc("POST /api/verify-email/resend", cfg.resendEmailVerification)
+	mux.HandleFunc("GET /api/sessions", cfg.getSessions)
+	mux.HandleFunc("POST /api/tokens", cfg.createPersonalAccessToken)
+	mux.HandleFunc("GET /api/tokens", cfg.getPersonalAccessTokens)
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", cfg.revokePersonalAccessToken)
+	mux.HandleFunc("POST /api/sessions/revoke-others", cfg.revokeOtherSessions)
+	mux.HandleFunc("DELETE /api/sessions/{sessionID}", cfg.revokeSession)
 	mux.HandleFunc("POST /api/users", cfg.createUser)
 	mux.HandleFunc("PUT /api/users", cfg.updateUser)
+	mux.HandleFunc("POST /api/users/2fa/totp", cfg.enrollTOTP)
+	mux.HandleFunc("POST /api/users/2fa/totp/verify", cfg.verifyTOTP)
+	mux.HandleFunc("DELETE /api/users/2fa/totp", cfg.disableTOTP)
+	mux.HandleFunc("GET /api/users/{username}", cfg.getUserProfile)
+	mux.HandleFunc("POST /api/users/{userID}/follow", cfg.followUser)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", cfg.unfollowUser)
+	mux.HandleFunc("GET /api/users/{userID}/followers", cfg.getFollowers)
+	mux.HandleFunc("GET /api/users/{userID}/following", cfg.getFollowing)
+	mux.HandleFunc("GET /api/users/me/mentions", cfg.getMyMentions)
+	mux.HandleFunc("GET /api/timeline", cfg.getTimeline)
 
 	mux.HandleFunc("POST /api/polka/webhooks", cfg.upgradeUserWebhookHandler)
 
+	mux.HandleFunc("POST /api/oauth/clients", cfg.createOAuthClient)
+	mux.HandleFunc("GET /oauth/authorize", cfg.authorizeOAuthClient)
+	mux.HandleFunc("POST /oauth/authorize", cfg.approveOAuthClient)
+	mux.HandleFunc("POST /oauth/token", cfg.exchangeOAuthCode)
+	mux.HandleFunc("POST /oauth/introspect", cfg.introspectOAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", cfg.revokeOAuthToken)
+
 	srv := &http.Server{
 		Addr:    ":" + port,
-		Handler: mux,
+		Handler: cfg.middlewareMetrics(mux),
 	}
 
+	go cfg.runWebhookWorker(context.Background())
+
+	if cfg.metricsToken == "" {
+		log.Printf("METRICS_TOKEN is not set, so /metrics will refuse every request\n")
+	}
 	log.Printf("Serving files from %s at: %s:%s\n", filepathRoot, "http://localhost", port)
 	log.Fatal(srv.ListenAndServe())
 }
+
+// newMailer sends mail over SMTP when MAILER=smtp. Otherwise messages are
+// written to MAIL_LOG_FILE, or stderr, for local development.
+func newMailer() (mailer.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Chirpy <no-reply@localhost>"
+	}
+	if os.Getenv("MAILER") == "smtp" {
+		return mailer.NewSMTP(os.Getenv("SMTP_ADDR"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	}
+	if path := os.Getenv("MAIL_LOG_FILE"); path != "" {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return nil, err
+		}
+		return mailer.NewLogMailer(f, from), nil
+	}
+	return mailer.NewLogMailer(os.Stderr, from), nil
+}
@@ -1,28 +1,254 @@
 package main
 
 import (
+	"chirpy/internal/auth"
+	"crypto/subtle"
+	"database/sql"
 	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"html/template"
+	"log"
 	"net/http"
+	"strconv"
+	"time"
 )
 
+// serverMetrics are the metrics Chirpy reports at /metrics.
+type serverMetrics struct {
+	registry          *prometheus.Registry
+	fileserverHits    prometheus.Counter
+	requests          *prometheus.CounterVec
+	requestDuration   *prometheus.HistogramVec
+	inFlight          prometheus.Gauge
+	bcryptDuration    *prometheus.HistogramVec
+	webhooksReceived  *prometheus.CounterVec
+	webhookDeliveries *prometheus.CounterVec
+}
+
+// bcryptBuckets fit bcrypt at the default cost, which takes tens of
+// milliseconds.
+var bcryptBuckets = []float64{0.01, 0.025, 0.05, 0.075, 0.1, 0.15, 0.25, 0.5, 1}
+
+func newServerMetrics(db *sql.DB) *serverMetrics {
+	r := prometheus.NewRegistry()
+	f := promauto.With(r)
+	m := &serverMetrics{
+		registry: r,
+		fileserverHits: f.NewCounter(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests for the static app under /app/.",
+		}),
+		requests: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests served, by route pattern, method and status code.",
+		}, []string{"pattern", "method", "code"}),
+		requestDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "Time to serve HTTP requests, by route pattern and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"pattern", "method"}),
+		inFlight: f.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+		bcryptDuration: f.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_bcrypt_duration_seconds",
+			Help:    "Time spent hashing and checking passwords.",
+			Buckets: bcryptBuckets,
+		}, []string{"operation"}),
+		webhooksReceived: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhooks_received_total",
+			Help: "Incoming webhook deliveries, by source and outcome (processed, ignored, duplicate, failed or rejected).",
+		}, []string{"source", "outcome"}),
+		webhookDeliveries: f.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_webhook_deliveries_total",
+			Help: "Outgoing webhook delivery attempts, by outcome (delivered, retrying or dead).",
+		}, []string{"outcome"}),
+	}
+
+	stats := func(fn func(sql.DBStats) float64) func() float64 {
+		return func() float64 { return fn(db.Stats()) }
+	}
+	gauge := func(name, help string, fn func(sql.DBStats) float64) {
+		f.NewGaugeFunc(prometheus.GaugeOpts{Name: name, Help: help}, stats(fn))
+	}
+	counter := func(name, help string, fn func(sql.DBStats) float64) {
+		f.NewCounterFunc(prometheus.CounterOpts{Name: name, Help: help}, stats(fn))
+	}
+	gauge("chirpy_db_max_open_connections", "Maximum number of open database connections.",
+		func(s sql.DBStats) float64 { return float64(s.MaxOpenConnections) })
+	gauge("chirpy_db_open_connections", "Open database connections.",
+		func(s sql.DBStats) float64 { return float64(s.OpenConnections) })
+	gauge("chirpy_db_in_use_connections", "Database connections in use.",
+		func(s sql.DBStats) float64 { return float64(s.InUse) })
+	gauge("chirpy_db_idle_connections", "Idle database connections.",
+		func(s sql.DBStats) float64 { return float64(s.Idle) })
+	counter("chirpy_db_wait_count_total", "Times a query waited for a free database connection.",
+		func(s sql.DBStats) float64 { return float64(s.WaitCount) })
+	counter("chirpy_db_wait_duration_seconds_total", "Time spent waiting for a free database connection.",
+		func(s sql.DBStats) float64 { return s.WaitDuration.Seconds() })
+	counter("chirpy_db_max_idle_closed_total", "Connections closed because too many were idle.",
+		func(s sql.DBStats) float64 { return float64(s.MaxIdleClosed) })
+	counter("chirpy_db_max_lifetime_closed_total", "Connections closed because they reached their maximum lifetime.",
+		func(s sql.DBStats) float64 { return float64(s.MaxLifetimeClosed) })
+	return m
+}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fileserverHits.Add(1)
+		cfg.metrics.fileserverHits.Inc()
 		next.ServeHTTP(w, r)
 	})
 }
 
-func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
-	body := fmt.Sprintf(`
+// statusRecorder remembers the status code a handler responded with.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// middlewareMetrics records every request against the mux pattern that
+// served it, rather than its path, so IDs in paths don't each become a
+// series. The mux sets r.Pattern while routing, so it's read afterwards.
+func (cfg *apiConfig) middlewareMetrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg.metrics.inFlight.Inc()
+		defer cfg.metrics.inFlight.Dec()
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		pattern := r.Pattern
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		cfg.metrics.requests.WithLabelValues(pattern, r.Method, strconv.Itoa(rec.status)).Inc()
+		cfg.metrics.requestDuration.WithLabelValues(pattern, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// handlerPrometheus serves /metrics to scrapers that send METRICS_TOKEN as a
+// bearer token. Routes and error rates aren't for the public, so with no
+// token configured nobody can read it.
+func (cfg *apiConfig) handlerPrometheus(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if cfg.metricsToken == "" || err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(cfg.metricsToken)) != 1 {
+		respondWithError(w, http.StatusUnauthorized, "Invalid metrics token")
+		return
+	}
+	promhttp.HandlerFor(cfg.metrics.registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+func (cfg *apiConfig) hashPassword(password string) (string, error) {
+	defer cfg.observeBcrypt("hash", time.Now())
+	return auth.HashPassword(password)
+}
+
+func (cfg *apiConfig) checkPasswordHash(password, hash string) error {
+	defer cfg.observeBcrypt("compare", time.Now())
+	return auth.CheckPasswordHash(password, hash)
+}
+
+func (cfg *apiConfig) observeBcrypt(operation string, start time.Time) {
+	cfg.metrics.bcryptDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+var metricsPage = template.Must(template.New("metrics").Parse(`
 <html>
 	<body>
 		<h1>Welcome, Chirpy Admin</h1>
-		<p>Chirpy has been visited %d times!</p>
+		<p>Chirpy has been visited {{.Hits}} times!</p>
+		{{range .Families}}
+		<h2>{{.Name}}</h2>
+		<p>{{.Help}}</p>
+		<table>
+			{{range .Samples}}<tr><td>{{.Name}}</td><td>{{range .Labels}}{{.GetName}}="{{.GetValue}}" {{end}}</td><td>{{.Value}}</td></tr>
+			{{end}}
+		</table>
+		{{end}}
 	</body>
 </html>
-`, cfg.fileserverHits.Load())
+`))
+
+// metricsFamily is one metric family as the admin page shows it.
+type metricsFamily struct {
+	Name    string
+	Help    string
+	Samples []metricsSample
+}
+
+type metricsSample struct {
+	Name   string
+	Labels []*dto.LabelPair
+	Value  float64
+}
+
+// handlerMetrics shows the same registry as /metrics, for people.
+// Histogram buckets are left to /metrics.
+func (cfg *apiConfig) handlerMetrics(w http.ResponseWriter, r *http.Request) {
+	families, err := cfg.metrics.registry.Gather()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, fmt.Sprintf("Error gathering metrics: %v", err))
+		return
+	}
+	type pageData struct {
+		Hits     int32
+		Families []metricsFamily
+	}
+	data := pageData{Hits: cfg.fileserverHits.Load()}
+	for _, f := range families {
+		family := metricsFamily{Name: f.GetName(), Help: f.GetHelp()}
+		for _, m := range f.GetMetric() {
+			sample := metricsSample{Name: f.GetName(), Labels: m.GetLabel()}
+			switch f.GetType() {
+			case dto.MetricType_COUNTER:
+				sample.Value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				sample.Value = m.GetGauge().GetValue()
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				family.Samples = append(family.Samples,
+					metricsSample{Name: f.GetName() + "_sum", Labels: m.GetLabel(), Value: h.GetSampleSum()},
+					metricsSample{Name: f.GetName() + "_count", Labels: m.GetLabel(), Value: float64(h.GetSampleCount())},
+				)
+				continue
+			default:
+				sample.Value = m.GetUntyped().GetValue()
+			}
+			family.Samples = append(family.Samples, sample)
+		}
+		data.Families = append(data.Families, family)
+	}
 
 	w.Header().Add("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(body))
+	err = metricsPage.Execute(w, data)
+	if err != nil {
+		log.Printf("Error rendering metrics page: %v", err)
+	}
 }
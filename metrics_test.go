@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandlerPrometheus(t *testing.T) {
+	db, err := sql.Open("postgres", "")
+	if err != nil {
+		t.Fatalf("sql.Open() error = %v", err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name         string
+		metricsToken string
+		authHeader   string
+		wantStatus   int
+	}{
+		{
+			name:       "No token configured",
+			authHeader: "Bearer ",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:         "Missing token",
+			metricsToken: "scrape-secret",
+			wantStatus:   http.StatusUnauthorized,
+		},
+		{
+			name:         "Wrong token",
+			metricsToken: "scrape-secret",
+			authHeader:   "Bearer guess",
+			wantStatus:   http.StatusUnauthorized,
+		},
+		{
+			name:         "Right token",
+			metricsToken: "scrape-secret",
+			authHeader:   "Bearer scrape-secret",
+			wantStatus:   http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &apiConfig{metrics: newServerMetrics(db), metricsToken: tt.metricsToken}
+			r := httptest.NewRequest("GET", "/metrics", nil)
+			if tt.authHeader != "" {
+				r.Header.Set("Authorization", tt.authHeader)
+			}
+			w := httptest.NewRecorder()
+			cfg.handlerPrometheus(w, r)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("handlerPrometheus() status = %d, want %d", w.Code, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(w.Body.String(), "chirpy_db_open_connections") {
+				t.Errorf("handlerPrometheus() body is missing chirpy_db_open_connections:\n%s", w.Body.String())
+			}
+		})
+	}
+}
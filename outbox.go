@@ -0,0 +1,133 @@
+package main
+
+import (
+	"chirpy/internal/database"
+	"chirpy/internal/webhook"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"github.com/google/uuid"
+	"log"
+	"time"
+)
+
+const (
+	webhookPollInterval = 5 * time.Second
+	webhookBatchSize    = 20
+	webhookSendTimeout  = 10 * time.Second
+	// webhookLease must outlast a batch of sends, or a slow batch could be
+	// claimed and sent a second time by another worker.
+	webhookLease = webhookBatchSize*webhookSendTimeout + time.Minute
+)
+
+type webhookEnvelope struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// webhookUser is what events say about a user. Email addresses are left
+// out of anything sent to a third party.
+type webhookUser struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username,omitempty"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type chirpDeletedEvent struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    uuid.UUID `json:"user_id"`
+	DeletedBy uuid.UUID `json:"deleted_by"`
+}
+
+// enqueueWebhookEvent queues an event for every endpoint subscribed to it.
+// Pass the transaction's queries so the event is only sent if the change
+// it describes commits.
+func enqueueWebhookEvent(ctx context.Context, q *database.Queries, eventType string, data any) error {
+	event := webhookEnvelope{
+		ID:        uuid.New(),
+		Type:      eventType,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventID:   event.ID,
+		EventType: eventType,
+		Payload:   string(payload),
+	})
+}
+
+// runWebhookWorker sends queued webhook deliveries until ctx is done.
+// Several servers can run it against one database; each claims its own
+// deliveries.
+func (cfg *apiConfig) runWebhookWorker(ctx context.Context) {
+	sender := webhook.NewSender(webhookSendTimeout)
+	ticker := time.NewTicker(webhookPollInterval)
+	defer ticker.Stop()
+	for {
+		// Keep going while there's a backlog, rather than sending one
+		// batch per tick.
+		for {
+			n, err := cfg.sendWebhookDeliveries(ctx, sender)
+			if err != nil {
+				log.Printf("Error sending webhooks: %v", err)
+			}
+			if err != nil || n < webhookBatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendWebhookDeliveries makes one attempt at each of a batch of due
+// deliveries and returns how many it claimed.
+func (cfg *apiConfig) sendWebhookDeliveries(ctx context.Context, sender *webhook.Sender) (int, error) {
+	deliveries, err := cfg.queries.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		BatchSize:    webhookBatchSize,
+		LeaseSeconds: int32(webhookLease / time.Second),
+	})
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, d := range deliveries {
+		sendErr := sender.Send(ctx, webhook.Delivery{
+			URL:       d.Url,
+			Secret:    d.Secret,
+			EventID:   d.EventID.String(),
+			EventType: d.EventType,
+			Payload:   []byte(d.Payload),
+		}, time.Now())
+		if sendErr == nil {
+			cfg.metrics.webhookDeliveries.WithLabelValues("delivered").Inc()
+			errs = append(errs, cfg.queries.MarkWebhookDeliveryDelivered(ctx, d.ID))
+			continue
+		}
+		retry := sql.NullInt32{}
+		if wait, ok := webhook.Backoff(int(d.Attempts) + 1); ok {
+			cfg.metrics.webhookDeliveries.WithLabelValues("retrying").Inc()
+			retry = sql.NullInt32{Int32: int32(wait / time.Second), Valid: true}
+		} else {
+			cfg.metrics.webhookDeliveries.WithLabelValues("dead").Inc()
+			log.Printf("Webhook delivery %s dead-lettered after %d attempts: %v", d.ID, d.Attempts+1, sendErr)
+		}
+		errs = append(errs, cfg.queries.MarkWebhookDeliveryFailed(ctx, database.MarkWebhookDeliveryFailedParams{
+			RetryInSeconds: retry,
+			LastError:      sql.NullString{String: sendErr.Error(), Valid: true},
+			ID:             d.ID,
+		}))
+	}
+	return len(deliveries), errors.Join(errs...)
+}
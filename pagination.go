@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"github.com/google/uuid"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// pageCursor marks the last row of a page. Chirp listings are ordered by
+// (created_at, id), so the pair is enough to resume from any position.
+// Ranked search results also carry the row's rank, which sorts first.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+	Rank      float32
+}
+
+type pageParams struct {
+	Limit  int32
+	Cursor *pageCursor
+}
+
+func encodeCursor(c pageCursor) string {
+	raw := c.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + c.ID.String()
+	if c.Rank != 0 {
+		raw += "|" + strconv.FormatFloat(float64(c.Rank), 'g', -1, 32)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(s string) (pageCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	parts := strings.Split(string(raw), "|")
+	if len(parts) != 2 && len(parts) != 3 {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	t, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	uid, err := uuid.Parse(parts[1])
+	if err != nil {
+		return pageCursor{}, errors.New("malformed cursor")
+	}
+	c := pageCursor{CreatedAt: t, ID: uid}
+	if len(parts) == 3 {
+		rank, err := strconv.ParseFloat(parts[2], 32)
+		if err != nil {
+			return pageCursor{}, errors.New("malformed cursor")
+		}
+		c.Rank = float32(rank)
+	}
+	return c, nil
+}
+
+func parsePageParams(r *http.Request) (pageParams, error) {
+	params := pageParams{Limit: defaultPageSize}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 1 {
+			return pageParams{}, errors.New("limit must be a positive integer")
+		}
+		params.Limit = int32(min(n, maxPageSize))
+	}
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		c, err := decodeCursor(cursor)
+		if err != nil {
+			return pageParams{}, err
+		}
+		params.Cursor = &c
+	}
+	return params, nil
+}
+
+// afterCreatedAt and afterID are the nullable query arguments for the
+// keyset condition; both are NULL on the first page.
+func (p pageParams) afterCreatedAt() sql.NullTime {
+	if p.Cursor == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: p.Cursor.CreatedAt, Valid: true}
+}
+
+func (p pageParams) afterID() uuid.NullUUID {
+	if p.Cursor == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: p.Cursor.ID, Valid: true}
+}
+
+func (p pageParams) afterRank() sql.NullFloat64 {
+	if p.Cursor == nil {
+		return sql.NullFloat64{}
+	}
+	return sql.NullFloat64{Float64: float64(p.Cursor.Rank), Valid: true}
+}
+
+// fetchLimit asks for one row more than the page size so we can tell
+// whether another page exists without a separate count query.
+func (p pageParams) fetchLimit() int32 {
+	return p.Limit + 1
+}
+
+// paginate trims rows fetched with fetchLimit down to the page size and
+// returns the cursor for the next page, or "" on the last page.
+func paginate[T any](rows []T, p pageParams, key func(T) pageCursor) ([]T, string) {
+	if len(rows) <= int(p.Limit) {
+		return rows, ""
+	}
+	rows = rows[:p.Limit]
+	return rows, encodeCursor(key(rows[len(rows)-1]))
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/google/uuid"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, 3, 1, 12, 30, 0, 123456789, time.UTC)
+	id := uuid.MustParse("3f1c6a52-7d2e-4b8a-9c1e-2f5d8b7a6c41")
+	tests := []struct {
+		name   string
+		cursor pageCursor
+	}{
+		{name: "Chirp listing", cursor: pageCursor{CreatedAt: createdAt, ID: id}},
+		{name: "Ranked search result", cursor: pageCursor{CreatedAt: createdAt, ID: id, Rank: 0.0607927}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeCursor(encodeCursor(tt.cursor))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if !got.CreatedAt.Equal(tt.cursor.CreatedAt) || got.ID != tt.cursor.ID || got.Rank != tt.cursor.Rank {
+				t.Errorf("decodeCursor() = %+v, want %+v", got, tt.cursor)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorMalformed(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{name: "Not base64", cursor: "not a cursor!"},
+		{name: "Padded base64", cursor: base64.URLEncoding.EncodeToString([]byte("2024-03-01T12:30:00Z|3f1c6a52-7d2e-4b8a-9c1e-2f5d8b7a6c41|"))},
+		{name: "One part", cursor: encode("2024-03-01T12:30:00Z")},
+		{name: "Too many parts", cursor: encode("2024-03-01T12:30:00Z|3f1c6a52-7d2e-4b8a-9c1e-2f5d8b7a6c41|0.5|x")},
+		{name: "Bad time", cursor: encode("yesterday|3f1c6a52-7d2e-4b8a-9c1e-2f5d8b7a6c41")},
+		{name: "Bad ID", cursor: encode("2024-03-01T12:30:00Z|42")},
+		{name: "Bad rank", cursor: encode("2024-03-01T12:30:00Z|3f1c6a52-7d2e-4b8a-9c1e-2f5d8b7a6c41|high")},
+		{name: "Empty rank", cursor: encode("2024-03-01T12:30:00Z|3f1c6a52-7d2e-4b8a-9c1e-2f5d8b7a6c41|")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q) error = nil, want an error", tt.cursor)
+			}
+		})
+	}
+}
@@ -1,9 +1,155 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
 
-func handlerReadiness(w http.ResponseWriter, r *http.Request) {
+// readinessTimeout bounds each readiness check, so a hung database fails
+// the probe instead of stalling it.
+const readinessTimeout = 2 * time.Second
+
+//go:embed sql/schema/*.sql
+var schemaFiles embed.FS
+
+// latestSchemaVersion is the version of the newest goose migration in
+// fsys, which is what the database should be migrated to.
+func latestSchemaVersion(fsys fs.FS) (int64, error) {
+	names, err := fs.Glob(fsys, "sql/schema/*.sql")
+	if err != nil {
+		return 0, err
+	}
+	var latest int64
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(path.Base(name), "_")
+		version, err := strconv.ParseInt(prefix, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("migration %s has no version: %w", name, err)
+		}
+		latest = max(latest, version)
+	}
+	if latest == 0 {
+		return 0, fmt.Errorf("no migrations found")
+	}
+	return latest, nil
+}
+
+// handlerHealthz is the original health check, kept for whatever still
+// polls it. It means the same as /api/livez.
+func handlerHealthz(w http.ResponseWriter, r *http.Request) {
 	w.Header().Add("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte(http.StatusText(http.StatusOK)))
 }
+
+// handlerLiveness only says the process is serving requests. It doesn't
+// look at the database, so an outage doesn't get every pod restarted.
+func handlerLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type readinessCheck struct {
+	Status          string   `json:"status"`
+	Error           string   `json:"error,omitempty"`
+	Version         int64    `json:"version,omitempty"`
+	ExpectedVersion int64    `json:"expected_version,omitempty"`
+	Missing         []string `json:"missing,omitempty"`
+}
+
+func (c readinessCheck) ok() bool {
+	return c.Status == "ok"
+}
+
+func failedCheck(format string, args ...any) readinessCheck {
+	return readinessCheck{Status: "failed", Error: fmt.Sprintf(format, args...)}
+}
+
+func (cfg *apiConfig) checkDatabase(ctx context.Context) readinessCheck {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	err := cfg.db.PingContext(ctx)
+	if err != nil {
+		log.Printf("Readiness: database ping failed: %v", err)
+		return failedCheck("database unreachable")
+	}
+	return readinessCheck{Status: "ok"}
+}
+
+// checkMigrations compares goose's record of applied migrations with the
+// ones built into this binary. A database that is ahead still passes:
+// migrations run before a deploy, while the old pods are still serving.
+func (cfg *apiConfig) checkMigrations(ctx context.Context) readinessCheck {
+	ctx, cancel := context.WithTimeout(ctx, readinessTimeout)
+	defer cancel()
+	// goose records downs as well as ups; a version counts if its latest
+	// record is an up.
+	var version int64
+	err := cfg.db.QueryRowContext(ctx, `
+SELECT coalesce(max(version_id), 0) FROM goose_db_version v
+WHERE is_applied
+  AND id = (SELECT max(id) FROM goose_db_version WHERE version_id = v.version_id)`).Scan(&version)
+	if err != nil {
+		log.Printf("Readiness: reading migration version failed: %v", err)
+		return failedCheck("couldn't read migration version")
+	}
+	check := readinessCheck{Status: "ok", Version: version, ExpectedVersion: cfg.schemaVersion}
+	if version < cfg.schemaVersion {
+		check.Status = "failed"
+		check.Error = "database is behind this build's migrations"
+	}
+	return check
+}
+
+func (cfg *apiConfig) checkConfig() readinessCheck {
+	missing := []string{}
+	if cfg.secretToken == "" {
+		missing = append(missing, "SECRET_TOKEN")
+	}
+	if cfg.polkaKeys[0] == "" {
+		missing = append(missing, "POLKA_KEY")
+	}
+	if len(missing) > 0 {
+		return readinessCheck{Status: "failed", Error: "required settings are missing", Missing: missing}
+	}
+	return readinessCheck{Status: "ok"}
+}
+
+// handlerReadiness reports whether this instance can serve traffic: the
+// database answers, is migrated far enough, and required config is set.
+// Any failed check makes it a 503, so the pod is taken out of rotation.
+func (cfg *apiConfig) handlerReadiness(w http.ResponseWriter, r *http.Request) {
+	type readinessResponse struct {
+		Status string                    `json:"status"`
+		Checks map[string]readinessCheck `json:"checks"`
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	resp := readinessResponse{Status: "ok", Checks: map[string]readinessCheck{
+		"config": cfg.checkConfig(),
+	}}
+	resp.Checks["database"] = cfg.checkDatabase(r.Context())
+	if resp.Checks["database"].ok() {
+		resp.Checks["migrations"] = cfg.checkMigrations(r.Context())
+	} else {
+		resp.Checks["migrations"] = readinessCheck{Status: "skipped"}
+	}
+
+	code := http.StatusOK
+	for _, check := range resp.Checks {
+		if check.Status == "failed" {
+			resp.Status = "unavailable"
+			code = http.StatusServiceUnavailable
+		}
+	}
+	respondWithJSON(w, code, resp)
+}
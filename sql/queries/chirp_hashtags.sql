@@ -0,0 +1,38 @@
+-- name: CreateChirpHashtags :exec
+-- created_at is copied from the chirp so tag pages and trending windows
+-- line up with the chirp's own timestamp.
+INSERT INTO chirp_hashtags (chirp_id, tag, created_at)
+SELECT sqlc.arg(chirp_id)::uuid, tag, sqlc.arg(created_at)::timestamp
+FROM unnest(sqlc.arg(tags)::text[]) AS tag
+ON CONFLICT (chirp_id, tag) DO NOTHING;
+
+-- name: DeleteChirpHashtags :exec
+DELETE FROM chirp_hashtags
+WHERE chirp_id = $1;
+
+-- name: GetChirpsByHashtag :many
+SELECT chirps.* FROM chirps
+JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+WHERE chirp_hashtags.tag = sqlc.arg(tag)
+AND (
+    sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (chirps.created_at, chirps.id) < (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+)
+ORDER BY chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: GetTrendingHashtags :many
+-- Counts each tag's uses in the current window and in the window of the
+-- same length before it. Tags that are growing fastest come first.
+SELECT tag, recent_count, previous_count FROM (
+    SELECT
+        tag,
+        COUNT(*) FILTER (WHERE created_at >= sqlc.arg(window_start)::timestamp) AS recent_count,
+        COUNT(*) FILTER (WHERE created_at < sqlc.arg(window_start)::timestamp) AS previous_count
+    FROM chirp_hashtags
+    WHERE created_at >= sqlc.arg(previous_window_start)::timestamp
+    GROUP BY tag
+) AS counts
+WHERE recent_count > 0
+ORDER BY recent_count - previous_count DESC, recent_count DESC, tag
+LIMIT sqlc.arg(max_tags);
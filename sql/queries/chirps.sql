@@ -1,40 +1,141 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
-VALUES (
-        gen_random_uuid(),
-        now() at time zone 'utc',
-        now() at time zone 'utc',
-        $1,
-        $2
-)
+-- Replies join their parent's conversation; a new top-level chirp starts
+-- its own conversation, keyed by its own ID.
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, in_reply_to, conversation_id, quote_of)
+SELECT
+    new_chirp.id,
+    now() at time zone 'utc',
+    now() at time zone 'utc',
+    sqlc.arg(body)::text,
+    sqlc.arg(user_id)::uuid,
+    parent.id,
+    COALESCE(parent.conversation_id, new_chirp.id),
+    sqlc.narg(quote_of)::uuid
+FROM (SELECT gen_random_uuid() AS id) AS new_chirp
+LEFT JOIN chirps AS parent ON parent.id = sqlc.narg(in_reply_to)::uuid
+RETURNING *;
+
+-- name: CreateRechirp :one
+-- A rechirp only points at the original chirp; it has no body of its own.
+-- Returns no row if the user has already rechirped that chirp.
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, conversation_id, rechirp_of)
+SELECT
+    new_chirp.id,
+    now() at time zone 'utc',
+    now() at time zone 'utc',
+    '',
+    sqlc.arg(user_id)::uuid,
+    new_chirp.id,
+    sqlc.arg(rechirp_of)::uuid
+FROM (SELECT gen_random_uuid() AS id) AS new_chirp
+ON CONFLICT (user_id, rechirp_of) WHERE rechirp_of IS NOT NULL DO NOTHING
 RETURNING *;
 
+-- name: GetRechirp :one
+SELECT * FROM chirps
+WHERE user_id = $1
+AND rechirp_of = $2;
+
+-- name: DeleteRechirp :exec
+DELETE FROM chirps
+WHERE user_id = $1
+AND rechirp_of = $2;
+
 -- name: DeleteChirps :exec
 DELETE FROM chirps;
 
 -- name: GetChirps :many
 SELECT * FROM chirps
-ORDER BY created_at;
+WHERE sqlc.narg(after_created_at)::timestamp IS NULL
+   OR (created_at, id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+ORDER BY created_at, id
+LIMIT sqlc.arg(page_size);
 
 -- name: GetChirpsDesc :many
 SELECT * FROM chirps
-ORDER BY created_at DESC;
+WHERE sqlc.narg(after_created_at)::timestamp IS NULL
+   OR (created_at, id) < (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
 
 -- name: GetChirpsByUserId :many
 SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at;
+WHERE user_id = sqlc.arg(user_id)
+AND (
+    sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (created_at, id) > (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+)
+ORDER BY created_at, id
+LIMIT sqlc.arg(page_size);
 
 -- name: GetChirpsByUserIdDesc :many
 SELECT * FROM chirps
-WHERE user_id = $1
-ORDER BY created_at DESC;
+WHERE user_id = sqlc.arg(user_id)
+AND (
+    sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
 
 -- name: GetChirp :one
 SELECT * FROM chirps
 WHERE id = $1;
 
+-- name: GetChirpForUpdate :one
+SELECT * FROM chirps
+WHERE id = $1
+FOR UPDATE;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $1, edited = TRUE, updated_at = now() at time zone 'utc'
+WHERE id = $2
+RETURNING *;
+
 -- name: DeleteChirp :exec
 DELETE FROM chirps
 WHERE id = $1
-AND user_id = $2;
\ No newline at end of file
+AND user_id = $2;
+
+-- name: GetThreadChirpIDs :many
+-- The chirp, its parents up to max_depth levels above it, and its replies
+-- up to max_depth levels below it. Replies are taken level by level, oldest
+-- first, and stop at max_replies, so a reply's parent is never cut off
+-- while the reply is kept.
+WITH RECURSIVE ancestors AS (
+    SELECT id, in_reply_to, 0 AS depth
+    FROM chirps
+    WHERE id = sqlc.arg(chirp_id)::uuid
+    UNION ALL
+    SELECT chirps.id, chirps.in_reply_to, ancestors.depth + 1
+    FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.in_reply_to
+    WHERE ancestors.depth < sqlc.arg(max_depth)::INTEGER
+), replies AS (
+    SELECT id, created_at, 1 AS depth
+    FROM chirps
+    WHERE in_reply_to = sqlc.arg(chirp_id)::uuid
+    UNION ALL
+    SELECT chirps.id, chirps.created_at, replies.depth + 1
+    FROM chirps
+    JOIN replies ON chirps.in_reply_to = replies.id
+    WHERE replies.depth < sqlc.arg(max_depth)::INTEGER
+)
+SELECT ancestors.id FROM ancestors
+UNION ALL
+(
+    SELECT replies.id FROM replies
+    ORDER BY replies.depth, replies.created_at, replies.id
+    LIMIT sqlc.arg(max_replies)::INTEGER
+);
+
+-- name: ReparentReplies :exec
+UPDATE chirps
+SET in_reply_to = sqlc.narg(new_parent_id)
+WHERE in_reply_to = sqlc.arg(chirp_id)::uuid;
+
+-- name: GetChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+ORDER BY created_at, id;
@@ -0,0 +1,19 @@
+-- name: CreateEmailVerificationToken :exec
+INSERT INTO email_verification_tokens (token_hash, user_id, email, created_at, expires_at)
+VALUES (
+           $1,
+           $2,
+           $3,
+           now() at time zone 'utc',
+           now() at time zone 'utc' + interval '24 hours'
+       );
+
+-- name: UseEmailVerificationToken :one
+-- Spends a verification token. Returns no rows if it doesn't exist, has
+-- expired, or was already used.
+UPDATE email_verification_tokens
+SET used_at = now() at time zone 'utc'
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > now() at time zone 'utc'
+RETURNING user_id, email;
\ No newline at end of file
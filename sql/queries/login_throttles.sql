@@ -0,0 +1,30 @@
+-- name: RecordLoginAttempt :one
+-- Counts an attempt before it is checked, holding the key's row lock until
+-- the transaction ends. The count starts over once a key has gone
+-- reset_after_seconds without an attempt. retry_after is how long the key
+-- was already locked for, or 0.
+INSERT INTO login_throttles (key, failures, updated_at)
+VALUES (sqlc.arg(key), 1, now() at time zone 'utc')
+ON CONFLICT (key) DO UPDATE
+SET
+    failures = CASE
+        WHEN login_throttles.updated_at < now() at time zone 'utc' - make_interval(secs => sqlc.arg(reset_after_seconds)::INTEGER) THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    updated_at = now() at time zone 'utc'
+RETURNING failures, GREATEST(COALESCE(CEIL(EXTRACT(EPOCH FROM locked_until - now() at time zone 'utc')), 0), 0)::INTEGER AS retry_after;
+
+-- name: LockLogin :exec
+UPDATE login_throttles
+SET locked_until = now() at time zone 'utc' + make_interval(secs => sqlc.arg(lock_seconds)::INTEGER)
+WHERE key = sqlc.arg(key);
+
+-- name: ReleaseLoginAttempt :exec
+-- Takes back an attempt that turned out to be a successful login.
+UPDATE login_throttles
+SET failures = GREATEST(failures - 1, 0)
+WHERE key = ANY(sqlc.arg(keys)::TEXT[]);
+
+-- name: ClearLoginFailures :exec
+DELETE FROM login_throttles
+WHERE key = $1;
@@ -0,0 +1,69 @@
+-- name: CreateOAuthClient :one
+INSERT INTO oauth_clients (id, secret_hash, name, redirect_uris, owner_id, created_at)
+VALUES (
+           $1,
+           $2,
+           $3,
+           $4,
+           $5,
+           now() at time zone 'utc'
+       )
+RETURNING *;
+
+-- name: GetOAuthClient :one
+SELECT * FROM oauth_clients
+WHERE id = $1;
+
+-- name: CreateOAuthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+           $1,
+           $2,
+           $3,
+           $4,
+           $5,
+           $6,
+           now() at time zone 'utc',
+           now() at time zone 'utc' + interval '10 minutes'
+       );
+
+-- name: UseOAuthAuthorizationCode :one
+-- Spends an authorization code. Returns no rows if it doesn't exist, has
+-- expired, or was already used.
+UPDATE oauth_authorization_codes
+SET used_at = now() at time zone 'utc'
+WHERE code_hash = $1
+AND used_at IS NULL
+AND expires_at > now() at time zone 'utc'
+RETURNING *;
+
+-- name: CreateOAuthAccessToken :exec
+INSERT INTO oauth_access_tokens (id, client_id, user_id, scopes, authorization_code_hash, created_at, expires_at)
+VALUES (
+           $1,
+           $2,
+           $3,
+           $4,
+           $5,
+           now() at time zone 'utc',
+           $6
+       );
+
+-- name: GetOAuthAccessToken :one
+SELECT * FROM oauth_access_tokens
+WHERE id = $1;
+
+-- name: RevokeOAuthAccessToken :exec
+UPDATE oauth_access_tokens
+SET revoked_at = now() at time zone 'utc'
+WHERE id = $1
+AND client_id = $2
+AND revoked_at IS NULL;
+
+-- name: RevokeOAuthAccessTokensByCode :exec
+-- Revokes everything issued from an authorization code, for when the code
+-- is presented a second time.
+UPDATE oauth_access_tokens
+SET revoked_at = now() at time zone 'utc'
+WHERE authorization_code_hash = $1
+AND revoked_at IS NULL;
\ No newline at end of file
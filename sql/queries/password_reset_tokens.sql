@@ -0,0 +1,22 @@
+-- name: CreatePasswordResetToken :exec
+INSERT INTO password_reset_tokens (token_hash, user_id, created_at, expires_at)
+VALUES (
+           $1,
+           $2,
+           now() at time zone 'utc',
+           now() at time zone 'utc' + interval '1 hour'
+       );
+
+-- name: UsePasswordResetToken :one
+-- Spends a reset token. Returns no rows if it doesn't exist, has expired,
+-- or was already used.
+UPDATE password_reset_tokens
+SET used_at = now() at time zone 'utc'
+WHERE token_hash = $1
+AND used_at IS NULL
+AND expires_at > now() at time zone 'utc'
+RETURNING user_id;
+
+-- name: DeletePasswordResetTokens :exec
+DELETE FROM password_reset_tokens
+WHERE user_id = $1;
\ No newline at end of file
@@ -0,0 +1,41 @@
+-- name: CreatePersonalAccessToken :one
+INSERT INTO personal_access_tokens (id, user_id, name, token_hash, hint, scopes, created_at, expires_at)
+VALUES (
+           gen_random_uuid(),
+           $1,
+           $2,
+           $3,
+           $4,
+           $5,
+           now() at time zone 'utc',
+           $6
+       )
+RETURNING id, name, hint, scopes, created_at, last_used_at, expires_at;
+
+-- name: GetPersonalAccessTokens :many
+SELECT id, name, hint, scopes, created_at, last_used_at, expires_at FROM personal_access_tokens
+WHERE user_id = $1
+AND revoked_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: GetPersonalAccessTokenByHash :one
+-- Only returns tokens that are still usable.
+SELECT id, user_id, scopes FROM personal_access_tokens
+WHERE token_hash = $1
+AND revoked_at IS NULL
+AND (expires_at IS NULL OR expires_at > now() at time zone 'utc');
+
+-- name: TouchPersonalAccessToken :exec
+-- Records use at most once a minute, to keep busy bots from writing on
+-- every request.
+UPDATE personal_access_tokens
+SET last_used_at = now() at time zone 'utc'
+WHERE id = $1
+AND (last_used_at IS NULL OR last_used_at < now() at time zone 'utc' - interval '1 minute');
+
+-- name: RevokePersonalAccessToken :execrows
+UPDATE personal_access_tokens
+SET revoked_at = now() at time zone 'utc'
+WHERE id = $1
+AND user_id = $2
+AND revoked_at IS NULL;
\ No newline at end of file
@@ -1,11 +1,16 @@
 -- name: CreateRefreshToken :one
-INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at)
+INSERT INTO refresh_tokens (token, created_at, updated_at, user_id, expires_at, family_id, device_name, user_agent, ip, last_used_at)
 VALUES (
            $1,
            now() at time zone 'utc',
            now() at time zone 'utc',
            $2,
-           now() at time zone 'utc' + interval '60 days'
+           now() at time zone 'utc' + interval '60 days',
+           $3,
+           $4,
+           $5,
+           $6,
+           now() at time zone 'utc'
        )
 RETURNING *;
 
@@ -16,4 +21,54 @@ WHERE token = $1;
 -- name: RevokeRefreshToken :exec
 UPDATE refresh_tokens
 SET revoked_at = now() at time zone 'utc', updated_at = now() at time zone 'utc'
-WHERE token = $1;
\ No newline at end of file
+WHERE token = $1;
+
+-- name: RotateRefreshToken :execrows
+-- Retires a token in favor of its successor. Affects no rows if the token
+-- was already revoked or rotated, including by a concurrent request.
+UPDATE refresh_tokens
+SET revoked_at = now() at time zone 'utc', updated_at = now() at time zone 'utc', last_used_at = now() at time zone 'utc', replaced_by = $1
+WHERE token = $2
+AND revoked_at IS NULL;
+
+-- name: RevokeRefreshTokenFamily :exec
+UPDATE refresh_tokens
+SET revoked_at = now() at time zone 'utc', updated_at = now() at time zone 'utc'
+WHERE family_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetSessions :many
+-- A session is a token family; its live token carries the latest metadata.
+SELECT
+    family_id AS id,
+    device_name,
+    user_agent,
+    ip,
+    (SELECT MIN(f.created_at) FROM refresh_tokens f WHERE f.family_id = refresh_tokens.family_id)::TIMESTAMP AS signed_in_at,
+    last_used_at,
+    expires_at
+FROM refresh_tokens
+WHERE refresh_tokens.user_id = $1
+AND refresh_tokens.revoked_at IS NULL
+AND refresh_tokens.expires_at > now() at time zone 'utc'
+ORDER BY last_used_at DESC;
+
+-- name: RevokeSession :execrows
+UPDATE refresh_tokens
+SET revoked_at = now() at time zone 'utc', updated_at = now() at time zone 'utc'
+WHERE family_id = $1
+AND user_id = $2
+AND revoked_at IS NULL;
+
+-- name: RevokeOtherSessions :exec
+UPDATE refresh_tokens
+SET revoked_at = now() at time zone 'utc', updated_at = now() at time zone 'utc'
+WHERE user_id = $1
+AND family_id <> $2
+AND revoked_at IS NULL;
+
+-- name: RevokeUserRefreshTokens :exec
+UPDATE refresh_tokens
+SET revoked_at = now() at time zone 'utc', updated_at = now() at time zone 'utc'
+WHERE user_id = $1
+AND revoked_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,16 @@
+-- name: SearchChirps :many
+-- Results are ordered by rank, so the keyset includes the rank ahead of
+-- (created_at, id).
+SELECT sqlc.embed(chirps), ts_rank(chirps.search_vector, tsq)::real AS rank
+FROM chirps, websearch_to_tsquery('english', sqlc.arg(query)::text) AS tsq
+WHERE chirps.search_vector @@ tsq
+AND (sqlc.narg(author_id)::uuid IS NULL OR chirps.user_id = sqlc.narg(author_id)::uuid)
+AND (sqlc.narg(since)::timestamp IS NULL OR chirps.created_at >= sqlc.narg(since)::timestamp)
+AND (sqlc.narg(until)::timestamp IS NULL OR chirps.created_at < sqlc.narg(until)::timestamp)
+AND (
+    sqlc.narg(after_rank)::real IS NULL
+    OR (ts_rank(chirps.search_vector, tsq), chirps.created_at, chirps.id)
+        < (sqlc.narg(after_rank)::real, sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+)
+ORDER BY rank DESC, chirps.created_at DESC, chirps.id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,42 @@
+-- name: GetTOTP :one
+SELECT totp_secret, totp_enabled, totp_last_step FROM users
+WHERE id = $1;
+
+-- name: SetTOTPSecret :exec
+-- Starts enrollment. The secret isn't enforced until EnableTOTP.
+UPDATE users
+SET totp_secret = $1, totp_enabled = FALSE, totp_last_step = 0, updated_at = now() at time zone 'utc'
+WHERE id = $2;
+
+-- name: EnableTOTP :exec
+UPDATE users
+SET totp_enabled = TRUE, totp_last_step = $1, updated_at = now() at time zone 'utc'
+WHERE id = $2;
+
+-- name: DisableTOTP :exec
+UPDATE users
+SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0, updated_at = now() at time zone 'utc'
+WHERE id = $1;
+
+-- name: UseTOTPStep :execrows
+-- Records the time step of an accepted code. Affects no rows if that step
+-- or a later one was already used, so two requests can't both spend a code.
+UPDATE users
+SET totp_last_step = $1
+WHERE id = $2
+AND totp_last_step < $1;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO recovery_codes (user_id, code_hash, created_at)
+SELECT sqlc.arg(user_id)::uuid, unnest(sqlc.arg(code_hashes)::text[]), now() at time zone 'utc';
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE user_id = $1;
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = now() at time zone 'utc'
+WHERE user_id = $1
+AND code_hash = $2
+AND used_at IS NULL;
\ No newline at end of file
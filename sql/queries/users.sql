@@ -1,20 +1,23 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, username, display_name, bio)
 VALUES (
         gen_random_uuid(),
        now() at time zone 'utc',
     now() at time zone 'utc',
         $1,
-        $2
+        $2,
+        $3,
+        $4,
+        $5
 )
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role;
 
 -- name: GetUserHashedPasswordByEmail :one
 SELECT hashed_password FROM users
 WHERE email = $1;
 
 -- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, is_chirpy_red FROM users
+SELECT id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role FROM users
 WHERE email = $1;
 
 -- name: DeleteUsers :exec
@@ -22,12 +25,80 @@ DELETE FROM users;
 
 -- name: UpdateUser :one
 UPDATE users
-SET email = $1, updated_at = now() at time zone 'utc', hashed_password = $2
-WHERE id = $3
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
+SET
+    updated_at = now() at time zone 'utc',
+    hashed_password = COALESCE(sqlc.narg(hashed_password), hashed_password),
+    username = COALESCE(sqlc.narg(username), username),
+    display_name = COALESCE(sqlc.narg(display_name), display_name),
+    bio = COALESCE(sqlc.narg(bio), bio)
+WHERE id = sqlc.arg(id)
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role;
 
 -- name: UpgradeUser :one
 UPDATE users
 SET is_chirpy_red = $1
 WHERE id = $2
-RETURNING id, created_at, updated_at, email, is_chirpy_red;
\ No newline at end of file
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role;
+
+-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role FROM users
+WHERE id = $1;
+
+-- name: GetUsersByUsernames :many
+SELECT id, COALESCE(username, '') AS username FROM users
+WHERE lower(username) = ANY(sqlc.arg(usernames)::text[]);
+
+-- name: GetUserProfile :one
+-- Public view of a user. Never select email or hashed_password here.
+SELECT
+    id,
+    COALESCE(username, '') AS username,
+    display_name,
+    bio,
+    created_at AS joined_at,
+    is_chirpy_red,
+    (SELECT COUNT(*) FROM chirps WHERE chirps.user_id = users.id) AS chirp_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.followee_id = users.id) AS follower_count,
+    (SELECT COUNT(*) FROM follows WHERE follows.follower_id = users.id) AS following_count
+FROM users
+WHERE lower(username) = lower(sqlc.arg(username));
+
+
+-- name: SetUserPassword :exec
+UPDATE users
+SET hashed_password = $1, updated_at = now() at time zone 'utc'
+WHERE id = $2;
+
+-- name: SetPendingEmail :exec
+-- The current address stays in use until the new one is verified.
+UPDATE users
+SET pending_email = $1, updated_at = now() at time zone 'utc'
+WHERE id = $2;
+
+-- name: VerifyUserEmail :one
+-- Marks email as verified, switching to it if it was pending. Returns no
+-- rows if email is neither the current nor the pending address, which
+-- happens when the user has since asked for a different change.
+UPDATE users
+SET
+    email = sqlc.arg(email),
+    email_verified_at = now() at time zone 'utc',
+    pending_email = CASE WHEN pending_email = sqlc.arg(email) THEN NULL ELSE pending_email END,
+    updated_at = now() at time zone 'utc'
+WHERE id = sqlc.arg(id)
+AND (email = sqlc.arg(email) OR pending_email = sqlc.arg(email))
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role;
+
+-- name: IsEmailVerified :one
+SELECT (email_verified_at IS NOT NULL)::boolean AS email_verified FROM users
+WHERE id = $1;
+-- name: SetUserRole :one
+UPDATE users
+SET role = $1,
+    updated_at = now() at time zone 'utc'
+WHERE id = $2
+RETURNING id, created_at, updated_at, email, is_chirpy_red, COALESCE(username, '') AS username, display_name, bio, (email_verified_at IS NOT NULL)::boolean AS email_verified, COALESCE(pending_email, '') AS pending_email, role;
+
+-- name: CountUsersWithRole :one
+SELECT COUNT(*) FROM users
+WHERE role = $1;
@@ -0,0 +1,90 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, url, secret, event_types, created_at)
+VALUES (
+           gen_random_uuid(),
+           $1,
+           $2,
+           $3,
+           now() at time zone 'utc'
+       )
+RETURNING *;
+
+-- name: GetWebhookEndpoints :many
+SELECT * FROM webhook_endpoints
+ORDER BY created_at;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1;
+
+-- name: DeleteWebhookEndpoints :exec
+-- For resetting a dev database.
+TRUNCATE webhook_deliveries, webhook_endpoints;
+
+-- name: EnqueueWebhookDeliveries :exec
+-- Queues the event for every endpoint subscribed to its type.
+INSERT INTO webhook_deliveries (id, endpoint_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+SELECT gen_random_uuid(), id, sqlc.arg(event_id), sqlc.arg(event_type)::text, sqlc.arg(payload), 'pending', now() at time zone 'utc', now() at time zone 'utc'
+FROM webhook_endpoints
+WHERE sqlc.arg(event_type)::text = ANY(event_types);
+
+-- name: ClaimWebhookDeliveries :many
+-- Takes up to batch_size due deliveries and pushes their next attempt out
+-- by lease_seconds, so another worker won't send them meanwhile. If this
+-- worker dies mid-send, they come due again when the lease runs out.
+WITH due AS (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending'
+    AND next_attempt_at <= now() at time zone 'utc'
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+UPDATE webhook_deliveries
+SET next_attempt_at = now() at time zone 'utc' + make_interval(secs => sqlc.arg(lease_seconds)::INTEGER)
+FROM due, webhook_endpoints
+WHERE webhook_deliveries.id = due.id
+AND webhook_endpoints.id = webhook_deliveries.endpoint_id
+RETURNING webhook_deliveries.id, webhook_deliveries.event_id, webhook_deliveries.event_type, webhook_deliveries.payload, webhook_deliveries.attempts, webhook_endpoints.url, webhook_endpoints.secret;
+
+-- name: MarkWebhookDeliveryDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered',
+    attempts = attempts + 1,
+    last_error = NULL,
+    delivered_at = now() at time zone 'utc'
+WHERE id = $1;
+
+-- name: MarkWebhookDeliveryFailed :exec
+-- Schedules another attempt, or dead-letters the delivery when there are
+-- no more (retry_in_seconds is NULL).
+UPDATE webhook_deliveries
+SET status = CASE WHEN sqlc.narg(retry_in_seconds)::INTEGER IS NULL THEN 'dead' ELSE 'pending' END,
+    attempts = attempts + 1,
+    last_error = sqlc.arg(last_error),
+    next_attempt_at = CASE
+        WHEN sqlc.narg(retry_in_seconds)::INTEGER IS NULL THEN next_attempt_at
+        ELSE now() at time zone 'utc' + make_interval(secs => sqlc.narg(retry_in_seconds)::INTEGER)
+    END
+WHERE id = sqlc.arg(id);
+
+-- name: GetWebhookDeliveries :many
+-- Newest first, optionally only those with the given status.
+SELECT * FROM webhook_deliveries
+WHERE endpoint_id = sqlc.arg(endpoint_id)
+AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+AND (
+    sqlc.narg(after_created_at)::timestamp IS NULL
+    OR (created_at, id) < (sqlc.narg(after_created_at)::timestamp, sqlc.narg(after_id)::uuid)
+)
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
+
+-- name: RetryWebhookDelivery :execrows
+-- Puts a dead delivery back in the queue with a fresh set of attempts.
+UPDATE webhook_deliveries
+SET status = 'pending',
+    attempts = 0,
+    next_attempt_at = now() at time zone 'utc'
+WHERE id = $1
+AND status = 'dead';
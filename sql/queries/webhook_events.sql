@@ -0,0 +1,50 @@
+-- name: RecordWebhookEvent :execrows
+-- Returns 0 rows if the event was already seen.
+INSERT INTO webhook_events (id, source, event_id, event_type, payload, received_at)
+VALUES (
+           gen_random_uuid(),
+           $1,
+           $2,
+           $3,
+           $4,
+           now() at time zone 'utc'
+       )
+ON CONFLICT (source, event_id) DO NOTHING;
+
+-- name: LockWebhookEvent :one
+-- Holds the event until the transaction ends, so concurrent deliveries of
+-- the same event are processed one after the other.
+SELECT * FROM webhook_events
+WHERE source = $1
+AND event_id = $2
+FOR UPDATE;
+
+-- name: GetWebhookEvent :one
+SELECT * FROM webhook_events
+WHERE id = $1;
+
+-- name: MarkWebhookEventDone :exec
+UPDATE webhook_events
+SET status = $1,
+    error = NULL,
+    attempts = attempts + 1,
+    processed_at = now() at time zone 'utc'
+WHERE id = $2;
+
+-- name: MarkWebhookEventFailed :exec
+UPDATE webhook_events
+SET status = 'failed',
+    error = $1,
+    attempts = attempts + 1
+WHERE id = $2;
+
+-- name: GetWebhookEvents :many
+-- Newest first, optionally only those with the given status.
+SELECT * FROM webhook_events
+WHERE (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+AND (
+    sqlc.narg(after_received_at)::timestamp IS NULL
+    OR (received_at, id) < (sqlc.narg(after_received_at)::timestamp, sqlc.narg(after_id)::uuid)
+)
+ORDER BY received_at DESC, id DESC
+LIMIT sqlc.arg(page_size);
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chirps
+ADD COLUMN in_reply_to UUID REFERENCES chirps (id) ON DELETE SET NULL,
+ADD COLUMN conversation_id UUID;
+UPDATE chirps SET conversation_id = id;
+ALTER TABLE chirps ALTER COLUMN conversation_id SET NOT NULL;
+CREATE INDEX chirps_in_reply_to_idx ON chirps (in_reply_to);
+CREATE INDEX chirps_conversation_id_idx ON chirps (conversation_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chirps
+DROP COLUMN conversation_id,
+DROP COLUMN in_reply_to;
+-- +goose StatementEnd
@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE webhook_events
+ADD COLUMN id UUID NOT NULL UNIQUE DEFAULT gen_random_uuid(),
+ADD COLUMN event_type TEXT NOT NULL DEFAULT '',
+ADD COLUMN payload TEXT NOT NULL DEFAULT '',
+ADD COLUMN status TEXT NOT NULL DEFAULT 'received'
+    CHECK (status IN ('received', 'processed', 'ignored', 'failed')),
+ADD COLUMN error TEXT,
+ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN processed_at TIMESTAMP;
+-- Events recorded before this migration were only kept if they applied.
+UPDATE webhook_events
+SET status = 'processed', attempts = 1, processed_at = received_at;
+CREATE INDEX webhook_events_received_at_idx ON webhook_events (received_at, id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX webhook_events_received_at_idx;
+ALTER TABLE webhook_events
+DROP COLUMN id,
+DROP COLUMN event_type,
+DROP COLUMN payload,
+DROP COLUMN status,
+DROP COLUMN error,
+DROP COLUMN attempts,
+DROP COLUMN processed_at;
+-- +goose StatementEnd